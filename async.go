@@ -0,0 +1,456 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+)
+
+// Default settings applied by an AsyncClient unless overridden.
+const (
+	// DefaultAsyncQueueSize is the default maximum number of messages
+	// queued for delivery.
+	DefaultAsyncQueueSize int = 100
+
+	// DefaultAsyncWorkers is the default number of workers used to deliver
+	// queued messages.
+	DefaultAsyncWorkers int = 2
+)
+
+// ErrQueueFull is returned when a message cannot be queued for delivery
+// because the queue is full.
+var ErrQueueFull = errors.New("send queue is full")
+
+// ErrQueueClosed is returned when a message cannot be queued for delivery
+// because the queue is closed, or provided to a DeliveryFunc for any queued
+// message discarded when closing the queue.
+var ErrQueueClosed = errors.New("send queue is closed")
+
+// ErrMessageDropped is provided to a DeliveryFunc for a queued message that
+// was dropped to make room for a newer message.
+var ErrMessageDropped = errors.New("message dropped from send queue")
+
+// OverflowPolicy determines how an AsyncClient handles a new message when
+// the queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for room in the queue, honoring the cancellation
+	// or timeout of the provided context.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest discards the oldest queued message in order to
+	// make room for the new message.
+	OverflowDropOldest
+
+	// OverflowDropNewest rejects the new message with ErrQueueFull.
+	OverflowDropNewest
+)
+
+// Delivery describes the final outcome of delivering a queued message.
+type Delivery struct {
+	// WebhookURL is the destination of the message.
+	WebhookURL string
+
+	// Message is the queued message.
+	Message Message
+
+	// Err is the final error from delivering the message, or nil if the
+	// message was delivered successfully.
+	Err error
+
+	// Enqueued is the time that the message was queued.
+	Enqueued time.Time
+}
+
+// DeliveryFunc is called once for each queued message with the final
+// outcome of delivering the message. The function is called from worker
+// goroutines and should not block for long periods of time.
+type DeliveryFunc func(delivery Delivery)
+
+// AsyncConfig provides settings for an AsyncClient. Default values are used
+// for any zero value fields.
+type AsyncConfig struct {
+	// QueueSize is the maximum number of messages queued for delivery.
+	QueueSize int
+
+	// Workers is the number of workers used to deliver queued messages.
+	Workers int
+
+	// Overflow determines how a new message is handled when the queue is
+	// full.
+	Overflow OverflowPolicy
+
+	// SendTimeout is how long each delivery may take, including any retry
+	// attempts applied by the client.
+	SendTimeout time.Duration
+
+	// OnDelivery is called with the final outcome of each queued message.
+	OnDelivery DeliveryFunc
+}
+
+// asyncItem is a message queued for delivery.
+type asyncItem struct {
+	seq        uint64
+	webhookURL string
+	message    Message
+	snapshot   Message
+	enqueued   time.Time
+}
+
+// AsyncClient wraps a TeamsClient in order to deliver messages from a
+// bounded in-memory queue using a pool of workers. Messages for the same
+// webhook URL are delivered in the order they were queued, one at a time.
+//
+// Close should be called to stop the workers once the client is no longer
+// needed.
+type AsyncClient struct {
+	client *TeamsClient
+	config AsyncConfig
+
+	// ctx is the parent context for all deliveries; it is cancelled if
+	// pending messages are abandoned when closing the client.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	queues   map[string][]*asyncItem
+	ready    []string
+	active   map[string]bool
+	pending  int
+	inflight int
+	seq      uint64
+	closed   bool
+
+	// changed is closed and replaced whenever the queue state changes in
+	// order to wake any waiting goroutines.
+	changed chan struct{}
+
+	wg sync.WaitGroup
+}
+
+// NewAsyncClient creates an AsyncClient which delivers queued messages using
+// the given TeamsClient and starts its workers.
+func NewAsyncClient(client *TeamsClient, config AsyncConfig) *AsyncClient {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultAsyncQueueSize
+	}
+
+	if config.Workers <= 0 {
+		config.Workers = DefaultAsyncWorkers
+	}
+
+	if config.SendTimeout <= 0 {
+		config.SendTimeout = DefaultWebhookSendTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := AsyncClient{
+		client:  client,
+		config:  config,
+		ctx:     ctx,
+		cancel:  cancel,
+		queues:  make(map[string][]*asyncItem),
+		active:  make(map[string]bool),
+		changed: make(chan struct{}),
+	}
+
+	c.wg.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go c.worker()
+	}
+
+	return &c
+}
+
+// Send is a wrapper function around the SendWithContext method using a
+// background context.
+func (c *AsyncClient) Send(webhookURL string, message Message) error {
+	return c.SendWithContext(context.Background(), webhookURL, message)
+}
+
+// SendWithContext validates and prepares the given message and queues it for
+// delivery to the given webhook URL. The provided context only applies to
+// waiting for room in the queue; delivery takes place in the background and
+// the outcome is reported via the configured DeliveryFunc.
+func (c *AsyncClient) SendWithContext(ctx context.Context, webhookURL string, message Message) error {
+	if err := c.client.ValidateWebhook(webhookURL); err != nil {
+		return fmt.Errorf(
+			"failed to validate webhook URL: %w",
+			err,
+		)
+	}
+
+	if err := message.Validate(); err != nil {
+		return fmt.Errorf(
+			"failed to validate message: %w",
+			err,
+		)
+	}
+
+	// Take a snapshot of the message now so that the queued payload
+	// reflects the message content at the time it was queued. Message cards
+	// which may need to be split are cloned instead.
+	snapshot, err := snapshotMessage(message)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to prepare message: %w",
+			err,
+		)
+	}
+	if card, ok := message.(*messagecard.MessageCard); ok && c.client.splitOversizedMessages {
+		snapshot = card.Clone()
+	}
+
+	c.mu.Lock()
+
+	for !c.closed && c.pending >= c.config.QueueSize {
+		switch c.config.Overflow {
+		case OverflowDropNewest:
+			c.mu.Unlock()
+			return ErrQueueFull
+
+		case OverflowDropOldest:
+			if dropped := c.dropOldest(); dropped != nil {
+				c.mu.Unlock()
+				c.deliver(dropped, ErrMessageDropped)
+				c.mu.Lock()
+			}
+
+		default:
+			changed := c.changed
+			c.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("failed to queue message: %w", ctx.Err())
+			case <-changed:
+			}
+
+			c.mu.Lock()
+		}
+	}
+
+	if c.closed {
+		c.mu.Unlock()
+		return ErrQueueClosed
+	}
+
+	c.seq++
+	item := asyncItem{
+		seq:        c.seq,
+		webhookURL: webhookURL,
+		message:    message,
+		snapshot:   snapshot,
+		enqueued:   time.Now(),
+	}
+
+	if len(c.queues[webhookURL]) == 0 && !c.active[webhookURL] {
+		c.ready = append(c.ready, webhookURL)
+	}
+	c.queues[webhookURL] = append(c.queues[webhookURL], &item)
+	c.pending++
+	c.broadcast()
+
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Len returns the number of messages queued or in the process of being
+// delivered.
+func (c *AsyncClient) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.pending + c.inflight
+}
+
+// Flush blocks until all queued messages have been delivered or the given
+// context is cancelled, whichever occurs first. The context error is
+// returned if the context is cancelled first.
+func (c *AsyncClient) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	for c.pending > 0 || c.inflight > 0 {
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+
+		c.mu.Lock()
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Close stops accepting new messages and blocks until all queued messages
+// have been delivered or the given context is cancelled. If the context is
+// cancelled first, deliveries in progress are cancelled, any remaining
+// queued messages are discarded (reported with ErrQueueClosed) and the
+// context error is returned.
+func (c *AsyncClient) Close(ctx context.Context) error {
+	c.mu.Lock()
+	c.closed = true
+	c.broadcast()
+	c.mu.Unlock()
+
+	err := c.Flush(ctx)
+	if err != nil {
+		c.cancel()
+
+		c.mu.Lock()
+		var discarded []*asyncItem
+		for _, queue := range c.queues {
+			discarded = append(discarded, queue...)
+		}
+		c.queues = make(map[string][]*asyncItem)
+		c.ready = nil
+		c.pending = 0
+		c.broadcast()
+		c.mu.Unlock()
+
+		for _, item := range discarded {
+			c.deliver(item, ErrQueueClosed)
+		}
+	}
+
+	c.wg.Wait()
+	c.cancel()
+
+	return err
+}
+
+// worker delivers queued messages until the client is closed and the queue
+// is empty.
+func (c *AsyncClient) worker() {
+	defer c.wg.Done()
+
+	for {
+		c.mu.Lock()
+		for len(c.ready) == 0 {
+			if c.closed && c.pending == 0 {
+				c.mu.Unlock()
+				return
+			}
+
+			changed := c.changed
+			c.mu.Unlock()
+			<-changed
+			c.mu.Lock()
+		}
+
+		webhookURL := c.ready[0]
+		c.ready = c.ready[1:]
+
+		queue := c.queues[webhookURL]
+		item := queue[0]
+		if len(queue) == 1 {
+			delete(c.queues, webhookURL)
+		} else {
+			c.queues[webhookURL] = queue[1:]
+		}
+
+		c.active[webhookURL] = true
+		c.pending--
+		c.inflight++
+		c.broadcast()
+		c.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(c.ctx, c.config.SendTimeout)
+		err := c.client.SendWithContext(ctx, item.webhookURL, item.snapshot)
+		cancel()
+
+		c.deliver(item, err)
+
+		c.mu.Lock()
+		delete(c.active, webhookURL)
+		if len(c.queues[webhookURL]) > 0 {
+			c.ready = append(c.ready, webhookURL)
+		}
+		c.inflight--
+		c.broadcast()
+		c.mu.Unlock()
+	}
+}
+
+// dropOldest removes the oldest queued message which is not already being
+// delivered. The caller must hold the lock.
+func (c *AsyncClient) dropOldest() *asyncItem {
+	var oldest *asyncItem
+	for _, queue := range c.queues {
+		if len(queue) > 0 && (oldest == nil || queue[0].seq < oldest.seq) {
+			oldest = queue[0]
+		}
+	}
+
+	if oldest == nil {
+		return nil
+	}
+
+	webhookURL := oldest.webhookURL
+	queue := c.queues[webhookURL]
+	if len(queue) == 1 {
+		delete(c.queues, webhookURL)
+
+		for i, key := range c.ready {
+			if key == webhookURL {
+				c.ready = append(c.ready[:i], c.ready[i+1:]...)
+				break
+			}
+		}
+	} else {
+		c.queues[webhookURL] = queue[1:]
+	}
+
+	c.pending--
+	c.broadcast()
+
+	return oldest
+}
+
+// deliver reports the final outcome of a queued message to the configured
+// DeliveryFunc.
+func (c *AsyncClient) deliver(item *asyncItem, err error) {
+	if err != nil {
+		c.client.Logger().Error(
+			"failed to deliver message",
+			"webhook", c.client.loggedWebhookURL(item.webhookURL),
+			"error", err,
+		)
+	}
+
+	if c.config.OnDelivery == nil {
+		return
+	}
+
+	c.config.OnDelivery(Delivery{
+		WebhookURL: item.webhookURL,
+		Message:    item.message,
+		Err:        err,
+		Enqueued:   item.enqueued,
+	})
+}
+
+// broadcast wakes all goroutines waiting for a change in queue state. The
+// caller must hold the lock.
+func (c *AsyncClient) broadcast() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncClientPerWebhookOrdering(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]string)
+
+	httpClient := NewTestClient(func(req *http.Request) (*http.Response, error) {
+		var msg MessageCard
+		if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		received[req.URL.String()] = append(received[req.URL.String()], msg.Text)
+		mu.Unlock()
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(ExpectedWebhookURLResponseText)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	var delivered int
+	var deliveryErrs []error
+
+	client := NewAsyncClient(NewTeamsClient().SetHTTPClient(httpClient), AsyncConfig{
+		QueueSize: 100,
+		Workers:   4,
+		OnDelivery: func(d Delivery) {
+			mu.Lock()
+			defer mu.Unlock()
+			delivered++
+			if d.Err != nil {
+				deliveryErrs = append(deliveryErrs, d.Err)
+			}
+		},
+	})
+
+	webhookURLs := []string{
+		"https://outlook.office.com/webhook/aaa",
+		"https://outlook.office.com/webhook/bbb",
+		"https://outlook.office.com/webhook/ccc",
+	}
+
+	var want []string
+	for i := 0; i < 20; i++ {
+		want = append(want, fmt.Sprintf("message %d", i))
+		for _, webhookURL := range webhookURLs {
+			msgCard := NewMessageCard()
+			msgCard.Text = fmt.Sprintf("message %d", i)
+			assert.NoError(t, client.Send(webhookURL, &msgCard))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, client.Close(ctx))
+	assert.Equal(t, 0, client.Len())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, 60, delivered)
+	assert.Empty(t, deliveryErrs)
+	for _, webhookURL := range webhookURLs {
+		assert.Equal(t, want, received[webhookURL])
+	}
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "too late"
+	assert.True(t, errors.Is(client.Send(webhookURLs[0], &msgCard), ErrQueueClosed))
+}
+
+func TestAsyncClientOverflow(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+
+	tests := []struct {
+		name        string
+		overflow    OverflowPolicy
+		wantSendErr error
+		wantDropped []string
+	}{
+		{
+			name:        "drop newest",
+			overflow:    OverflowDropNewest,
+			wantSendErr: ErrQueueFull,
+		},
+		{
+			name:        "drop oldest",
+			overflow:    OverflowDropOldest,
+			wantDropped: []string{"queued 1"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			// Block delivery of the first message so that later messages
+			// remain queued.
+			release := make(chan struct{})
+			started := make(chan struct{}, 1)
+
+			httpClient := NewTestClient(func(req *http.Request) (*http.Response, error) {
+				started <- struct{}{}
+				<-release
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(ExpectedWebhookURLResponseText)),
+					Header:     make(http.Header),
+				}, nil
+			})
+
+			var mu sync.Mutex
+			var dropped []string
+
+			client := NewAsyncClient(NewTeamsClient().SetHTTPClient(httpClient), AsyncConfig{
+				QueueSize: 2,
+				Workers:   1,
+				Overflow:  test.overflow,
+				OnDelivery: func(d Delivery) {
+					if errors.Is(d.Err, ErrMessageDropped) {
+						mu.Lock()
+						dropped = append(dropped, d.Message.(*MessageCard).Text)
+						mu.Unlock()
+					}
+				},
+			})
+
+			send := func(text string) error {
+				msgCard := NewMessageCard()
+				msgCard.Text = text
+				return client.Send(webhookURL, &msgCard)
+			}
+
+			assert.NoError(t, send("in flight"))
+			<-started
+
+			assert.NoError(t, send("queued 1"))
+			assert.NoError(t, send("queued 2"))
+
+			err := send("overflow")
+			if test.wantSendErr != nil {
+				assert.True(t, errors.Is(err, test.wantSendErr))
+			} else {
+				assert.NoError(t, err)
+			}
+
+			go func() {
+				for range started {
+				}
+			}()
+			close(release)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			assert.NoError(t, client.Close(ctx))
+			close(started)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, test.wantDropped, dropped)
+		})
+	}
+}
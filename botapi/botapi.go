@@ -58,8 +58,10 @@ type Message struct {
 	Entities []Mention `json:"entities"`
 
 	// payload is a prepared Message in JSON format for submission or pretty
-	// printing.
-	payload *bytes.Buffer `json:"-"`
+	// printing. The prepared bytes are not modified once set so that the
+	// same payload can be replayed for retries, redirects or delivery to
+	// multiple endpoints.
+	payload []byte `json:"-"`
 }
 
 // Mention represents a mention in the message for a specific user.
@@ -137,6 +139,7 @@ func (m *Message) AddText(text string) *Message {
 		// Passing an empty text string is effectively a NOOP.
 	default:
 		m.Text += text
+		m.payload = nil
 	}
 
 	return m
@@ -149,7 +152,7 @@ func (m *Message) PrettyPrint() string {
 		var prettyJSON bytes.Buffer
 
 		// Validation is handled by the Message.Prepare() method.
-		_ = json.Indent(&prettyJSON, m.payload.Bytes(), "", "\t")
+		_ = json.Indent(&prettyJSON, m.payload, "", "\t")
 
 		return prettyJSON.String()
 	}
@@ -253,6 +256,7 @@ func (m *Message) AddMention(prependToText bool, separator string, mentions ...M
 		}
 
 		m.Entities = append(m.Entities, mention)
+		m.payload = nil
 
 		// Fallback to single space separator if user didn't specify one.
 		if separator == "" {
@@ -312,6 +316,7 @@ func (m *Message) Mention(displayName string, id string, prependToText bool) err
 		}
 
 		m.Entities = append(m.Entities, mention)
+		m.payload = nil
 
 		if prependToText {
 			m.Text = mention.Text + " " + m.Text
@@ -322,14 +327,13 @@ func (m *Message) Mention(displayName string, id string, prependToText bool) err
 }
 
 // Prepare handles tasks needed to prepare a given Message for delivery to an
-// endpoint. If specified, tasks are repeated regardless of whether a previous
-// Prepare call was made. Validation should be performed by the caller prior
-// to calling this method.
+// endpoint. The prepared payload is replaced if the Message has been
+// modified since a previous Prepare call or if recreate is specified.
+// Validation should be performed by the caller prior to calling this method.
+//
+// Prepare modifies the Message; use Snapshot or Clone to submit the same
+// Message from multiple goroutines.
 func (m *Message) Prepare(recreate bool) error {
-	if m.payload != nil && !recreate {
-		return nil
-	}
-
 	jsonMessage, err := json.Marshal(m)
 	if err != nil {
 		return fmt.Errorf(
@@ -338,13 +342,18 @@ func (m *Message) Prepare(recreate bool) error {
 		)
 	}
 
-	m.payload = bytes.NewBuffer(jsonMessage)
+	if recreate || !bytes.Equal(jsonMessage, m.payload) {
+		m.payload = jsonMessage
+	}
 
 	return nil
 }
 
 // Payload returns the prepared Message payload. The caller should call
 // Prepare() prior to calling this method, results are undefined otherwise.
+//
+// A new reader is returned for each call as required by the goteamsnotify
+// Message interface.
 func (m *Message) Payload() io.Reader {
-	return m.payload
+	return bytes.NewReader(m.payload)
 }
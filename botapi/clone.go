@@ -0,0 +1,76 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package botapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Snapshot is an immutable prepared Message payload. A Snapshot is safe for
+// concurrent use and is not affected by later changes to the Message it was
+// taken from.
+type Snapshot struct {
+	payload []byte
+}
+
+// Snapshot returns an immutable prepared payload reflecting the current
+// content of the Message. The Message is not modified; validation should be
+// performed by the caller prior to calling this method.
+func (m *Message) Snapshot() (*Snapshot, error) {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to prepare message: %w",
+			err,
+		)
+	}
+
+	return &Snapshot{payload: payload}, nil
+}
+
+// Validate is a no-op; the Message is validated before a Snapshot is taken.
+func (s *Snapshot) Validate() error {
+	return nil
+}
+
+// Prepare is a no-op; the payload is already prepared.
+func (s *Snapshot) Prepare(recreate bool) error {
+	return nil
+}
+
+// Payload returns a new reader for the prepared payload.
+func (s *Snapshot) Payload() io.Reader {
+	return bytes.NewReader(s.payload)
+}
+
+// Bytes returns a copy of the prepared payload.
+func (s *Snapshot) Bytes() []byte {
+	return append([]byte(nil), s.payload...)
+}
+
+// Clone returns a deep copy of the Message.
+func (m *Message) Clone() *Message {
+	if m == nil {
+		return nil
+	}
+
+	clone := *m
+
+	if m.Entities != nil {
+		clone.Entities = append([]Mention(nil), m.Entities...)
+	}
+
+	if m.payload != nil {
+		clone.payload = append([]byte(nil), m.payload...)
+	}
+
+	return &clone
+}
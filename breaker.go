@@ -0,0 +1,326 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Default settings applied by a CircuitBreaker unless overridden.
+const (
+	// DefaultCircuitFailureThreshold is the default number of consecutive
+	// failures which opens the circuit for a webhook URL.
+	DefaultCircuitFailureThreshold int = 5
+
+	// DefaultCircuitCoolDown is the default period of time that a circuit
+	// remains open before trial requests are allowed.
+	DefaultCircuitCoolDown = 30 * time.Second
+
+	// DefaultCircuitHalfOpenRequests is the default number of concurrent
+	// trial requests allowed while a circuit is half-open.
+	DefaultCircuitHalfOpenRequests int = 1
+)
+
+// circuitIdleTimeout is how long a circuit is kept after its cool-down
+// period once no further requests are made to the webhook URL. Idle circuits
+// are removed so that a long-running CircuitBreaker does not retain state for
+// every webhook URL it has seen.
+const circuitIdleTimeout = 10 * time.Minute
+
+// circuitSweepInterval is the minimum period of time between checks for idle
+// circuits.
+const circuitSweepInterval = time.Minute
+
+// ErrCircuitOpen indicates that a message was not submitted because the
+// circuit for the webhook URL is open. Use errors.As with a
+// *CircuitOpenError value for additional details.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of the circuit for a webhook URL.
+type CircuitState int
+
+const (
+	// CircuitClosed allows all requests.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen rejects all requests until the cool-down period elapses.
+	CircuitOpen
+
+	// CircuitHalfOpen allows a limited number of trial requests in order to
+	// determine whether the circuit should be closed again.
+	CircuitHalfOpen
+)
+
+// String returns the name of the circuit state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitOpenError is returned when a message is not submitted because the
+// circuit for the webhook URL is open.
+type CircuitOpenError struct {
+	// RetryAt is the time at which trial requests will be allowed.
+	RetryAt time.Time
+}
+
+// Error provides the time at which trial requests will be allowed.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v until %s", ErrCircuitOpen, e.RetryAt.Format(time.RFC3339))
+}
+
+// Is indicates that a CircuitOpenError matches ErrCircuitOpen.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitStateFunc is called when the circuit for a webhook URL changes
+// state. The function is called while the CircuitBreaker lock is held and
+// must not call CircuitBreaker methods.
+type CircuitStateFunc func(webhookURL string, from CircuitState, to CircuitState)
+
+// CircuitBreakerConfig provides settings for a CircuitBreaker. Default values
+// are used for any zero value fields.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures which opens the
+	// circuit for a webhook URL.
+	FailureThreshold int
+
+	// CoolDown is the period of time that a circuit remains open before
+	// trial requests are allowed.
+	CoolDown time.Duration
+
+	// HalfOpenRequests is the number of concurrent trial requests allowed
+	// while a circuit is half-open.
+	HalfOpenRequests int
+
+	// OnStateChange is called when the circuit for a webhook URL changes
+	// state.
+	OnStateChange CircuitStateFunc
+}
+
+// circuit tracks the state for a single webhook URL. Circuits are only kept
+// for webhook URLs with recent failures; a missing circuit is closed.
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	trials   int
+	updated  time.Time
+}
+
+// CircuitBreaker stops submitting messages to a webhook URL which is
+// consistently failing. After the configured number of consecutive failures
+// the circuit for the webhook URL is opened and messages fail fast with
+// ErrCircuitOpen. Once the cool-down period elapses, trial requests are
+// allowed; a successful trial closes the circuit while a failed trial opens
+// it again.
+//
+// Only failures which indicate a problem with the endpoint (see
+// IsRetryableError) are counted. A CircuitBreaker is safe for concurrent use
+// and may be shared by multiple clients.
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	config   CircuitBreakerConfig
+	clock    Clock
+	circuits map[string]*circuit
+	swept    time.Time
+}
+
+// NewCircuitBreaker creates a CircuitBreaker using the given settings.
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultCircuitFailureThreshold
+	}
+
+	if config.CoolDown <= 0 {
+		config.CoolDown = DefaultCircuitCoolDown
+	}
+
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = DefaultCircuitHalfOpenRequests
+	}
+
+	return &CircuitBreaker{
+		config:   config,
+		clock:    systemClock{},
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// SetClock accepts a custom Clock which replaces the default time-based
+// clock used to track the cool-down period.
+func (b *CircuitBreaker) SetClock(clock Clock) *CircuitBreaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clock = clock
+
+	return b
+}
+
+// State returns the current state of the circuit for the given webhook URL.
+func (b *CircuitBreaker) State(webhookURL string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[webhookURL]
+	if !ok {
+		return CircuitClosed
+	}
+
+	if c.state == CircuitOpen && !b.clock.Now().Before(c.openedAt.Add(b.config.CoolDown)) {
+		return CircuitHalfOpen
+	}
+
+	return c.state
+}
+
+// Reset closes the circuit for the given webhook URL.
+func (b *CircuitBreaker) Reset(webhookURL string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.circuits[webhookURL]; ok {
+		b.transition(nil, webhookURL, c, CircuitClosed)
+		delete(b.circuits, webhookURL)
+	}
+}
+
+// allow indicates whether a request to the given webhook URL by the given
+// client may be made. A *CircuitOpenError is returned if not. Each allowed
+// request must be followed by a call to record.
+func (b *CircuitBreaker) allow(client MessageSender, webhookURL string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	b.sweep(client, now)
+
+	c, ok := b.circuits[webhookURL]
+	if !ok {
+		return nil
+	}
+
+	switch c.state {
+	case CircuitOpen:
+		retryAt := c.openedAt.Add(b.config.CoolDown)
+		if now.Before(retryAt) {
+			return &CircuitOpenError{RetryAt: retryAt}
+		}
+
+		b.transition(client, webhookURL, c, CircuitHalfOpen)
+		fallthrough
+
+	case CircuitHalfOpen:
+		if c.trials >= b.config.HalfOpenRequests {
+			return &CircuitOpenError{RetryAt: now}
+		}
+		c.trials++
+		c.updated = now
+	}
+
+	return nil
+}
+
+// record updates the circuit for the given webhook URL using the result of
+// an allowed request. Requests abandoned because the given context was
+// cancelled by the caller or aborted by an Interceptor are not counted;
+// requests which timed out are counted as failures.
+func (b *CircuitBreaker) record(ctx context.Context, client MessageSender, webhookURL string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[webhookURL]
+
+	if ok && c.state == CircuitHalfOpen && c.trials > 0 {
+		c.trials--
+	}
+
+	switch {
+	case err != nil && (errors.Is(ctx.Err(), context.Canceled) || errors.Is(err, ErrSendAborted)):
+		// Neither a success nor a failure of the endpoint.
+
+	case err != nil && IsRetryableError(err):
+		if !ok {
+			c = &circuit{}
+			b.circuits[webhookURL] = c
+		}
+
+		c.failures++
+		c.updated = b.clock.Now()
+
+		if c.state == CircuitHalfOpen || c.failures >= b.config.FailureThreshold {
+			c.openedAt = c.updated
+			c.trials = 0
+			b.transition(client, webhookURL, c, CircuitOpen)
+		}
+
+	case ok:
+		// The endpoint responded, even if the request was rejected.
+		b.transition(client, webhookURL, c, CircuitClosed)
+		delete(b.circuits, webhookURL)
+	}
+}
+
+// sweep removes circuits without trial requests which have not been updated
+// since the cool-down period and idle timeout elapsed. Removed circuits are
+// closed. The caller must hold the lock.
+func (b *CircuitBreaker) sweep(client MessageSender, now time.Time) {
+	if now.Sub(b.swept) < circuitSweepInterval {
+		return
+	}
+	b.swept = now
+
+	for webhookURL, c := range b.circuits {
+		if c.trials == 0 && now.Sub(c.updated) >= b.config.CoolDown+circuitIdleTimeout {
+			b.transition(client, webhookURL, c, CircuitClosed)
+			delete(b.circuits, webhookURL)
+		}
+	}
+}
+
+// transition changes the state of a circuit, reporting the change to the
+// configured CircuitStateFunc and the logger of the given client. The
+// package logger is used if a client is not given. The caller must hold the
+// lock.
+func (b *CircuitBreaker) transition(client MessageSender, webhookURL string, c *circuit, to CircuitState) {
+	from := c.state
+	if from == to {
+		return
+	}
+
+	c.state = to
+
+	if client != nil {
+		client.Logger().Info(
+			"circuit state changed",
+			"webhook", client.loggedWebhookURL(webhookURL),
+			"from", from,
+			"to", to,
+		)
+	} else {
+		packageLogger.Info("circuit state changed", "from", from, "to", to)
+	}
+
+	if b.config.OnStateChange != nil {
+		b.config.OnStateChange(webhookURL, from, to)
+	}
+}
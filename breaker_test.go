@@ -0,0 +1,137 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+
+	var transitions []string
+	clock := &fakeClock{now: time.Now()}
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: 2,
+		CoolDown:         time.Minute,
+		OnStateChange: func(_ string, from CircuitState, to CircuitState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	}).SetClock(clock)
+
+	var requests int
+	client := NewTeamsClient().
+		SetCircuitBreaker(breaker).
+		SetHTTPClient(newScriptedTestClient(&requests,
+			scriptedResponse{status: http.StatusBadRequest},
+			scriptedResponse{status: http.StatusInternalServerError},
+			scriptedResponse{status: http.StatusInternalServerError},
+			scriptedResponse{status: http.StatusServiceUnavailable},
+			scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText},
+		))
+
+	send := func() error {
+		msgCard := NewMessageCard()
+		msgCard.Text = "Hello World"
+		return client.SendWithContext(context.Background(), webhookURL, &msgCard)
+	}
+
+	// Client errors do not count as endpoint failures.
+	assert.Error(t, send())
+	assert.Equal(t, CircuitClosed, breaker.State(webhookURL))
+
+	assert.Error(t, send())
+	assert.Error(t, send())
+	assert.Equal(t, CircuitOpen, breaker.State(webhookURL))
+
+	err := send()
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.False(t, IsRetryableError(err))
+
+	var circuitErr *CircuitOpenError
+	if assert.True(t, errors.As(err, &circuitErr)) {
+		assert.Equal(t, clock.now.Add(time.Minute), circuitErr.RetryAt)
+	}
+	assert.Equal(t, 3, requests)
+
+	// A failed trial opens the circuit again.
+	clock.now = clock.now.Add(time.Minute)
+	assert.Equal(t, CircuitHalfOpen, breaker.State(webhookURL))
+	assert.Error(t, send())
+	assert.Equal(t, CircuitOpen, breaker.State(webhookURL))
+
+	// A successful trial closes the circuit.
+	clock.now = clock.now.Add(time.Minute)
+	assert.NoError(t, send())
+	assert.Equal(t, CircuitClosed, breaker.State(webhookURL))
+	assert.Equal(t, 5, requests)
+
+	assert.Equal(t, []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	}, transitions)
+}
+
+func TestCircuitBreakerEvictsIdleCircuits(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+	otherWebhookURL := "https://outlook.office.com/webhook/yyy"
+
+	var transitions []string
+	clock := &fakeClock{now: time.Now()}
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: 1,
+		CoolDown:         time.Minute,
+		OnStateChange: func(_ string, from CircuitState, to CircuitState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	}).SetClock(clock)
+
+	logger := &recordingLogger{}
+	var requests int
+	client := NewTeamsClient(
+		WithCircuitBreaker(breaker),
+		WithLogger(logger),
+		WithHTTPClient(newScriptedTestClient(&requests,
+			scriptedResponse{status: http.StatusInternalServerError},
+			scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText},
+		)),
+	)
+
+	send := func(webhookURL string) error {
+		msgCard := NewMessageCard()
+		msgCard.Text = "Hello World"
+		return client.SendWithContext(context.Background(), webhookURL, &msgCard)
+	}
+
+	// State changes are logged by the client.
+	assert.Error(t, send(webhookURL))
+	assert.Equal(t, CircuitOpen, breaker.State(webhookURL))
+	assert.Contains(t, logger.entries,
+		"info circuit state changed [webhook https://outlook.office.com/REDACTED from closed to open]",
+	)
+
+	// Successful submissions are not tracked.
+	assert.NoError(t, send(otherWebhookURL))
+	assert.Len(t, breaker.circuits, 1)
+
+	// Circuits are removed once idle.
+	clock.now = clock.now.Add(time.Minute + circuitIdleTimeout)
+	assert.NoError(t, send(otherWebhookURL))
+	assert.Empty(t, breaker.circuits)
+	assert.Equal(t, CircuitClosed, breaker.State(webhookURL))
+	assert.Equal(t, []string{"closed->open", "open->closed"}, transitions)
+}
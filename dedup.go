@@ -0,0 +1,691 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmasci/go-teams-notify/v2/botapi"
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+)
+
+// DefaultDedupTTL is the default period of time during which repeats of a
+// message are suppressed.
+const DefaultDedupTTL = 10 * time.Minute
+
+// File store settings.
+const (
+	fileFingerprintStoreName     = "fingerprints.json"
+	fileFingerprintStoreLockName = "fingerprints.lock"
+
+	// fileFingerprintStoreLockTimeout is how long to wait to acquire the
+	// store lock before giving up.
+	fileFingerprintStoreLockTimeout = 10 * time.Second
+
+	// fileFingerprintStoreStaleLock is the age at which a lock file is
+	// assumed to have been left behind by a process that exited
+	// unexpectedly.
+	fileFingerprintStoreStaleLock = 30 * time.Second
+
+	// fileFingerprintStoreLockPoll is the delay between attempts to acquire
+	// the store lock.
+	fileFingerprintStoreLockPoll = 10 * time.Millisecond
+)
+
+// ErrFingerprintStoreLocked is returned when a FileFingerprintStore lock
+// could not be acquired in time.
+var ErrFingerprintStoreLocked = errors.New("fingerprint store is locked")
+
+// FingerprintRecord tracks occurrences of a message within a deduplication
+// window.
+type FingerprintRecord struct {
+	// Fingerprint identifies the message.
+	Fingerprint string `json:"fingerprint"`
+
+	// WebhookURL is the destination of the message.
+	WebhookURL string `json:"webhookURL"`
+
+	// Description is a brief description of the message used when reporting
+	// suppressed occurrences.
+	Description string `json:"description"`
+
+	// Since is the time that the message was last sent, starting the
+	// current deduplication window.
+	Since time.Time `json:"since"`
+
+	// Suppressed is the number of occurrences suppressed since the message
+	// was last sent.
+	Suppressed int `json:"suppressed"`
+}
+
+// FingerprintStore tracks message fingerprints for a Deduplicator. All
+// operations must be atomic, including across processes for stores shared
+// by multiple processes.
+type FingerprintStore interface {
+	// Observe records an occurrence of the message described by the given
+	// record. If a record with the same fingerprint exists whose window has
+	// not yet elapsed (relative to the given ttl), its suppressed count is
+	// incremented and true is returned. Otherwise, the given record is
+	// stored, starting a new window, and any replaced record with
+	// suppressed occurrences is returned.
+	Observe(record FingerprintRecord, ttl time.Duration) (suppress bool, replaced *FingerprintRecord, err error)
+
+	// Expire removes all records whose window has elapsed as of the given
+	// time (relative to the given ttl) and returns the removed records with
+	// suppressed occurrences.
+	Expire(now time.Time, ttl time.Duration) ([]FingerprintRecord, error)
+
+	// Remove removes the stored record with the fingerprint of the given
+	// record if it is the given record (i.e., it has the same Since time)
+	// and no occurrences have been suppressed since it was stored. This
+	// allows a record stored by Observe to be rolled back without losing
+	// occurrences recorded by later calls.
+	Remove(record FingerprintRecord) error
+}
+
+// DedupConfig provides settings for a Deduplicator. Default values are used
+// for any zero value fields.
+type DedupConfig struct {
+	// TTL is the period of time after a message is sent during which
+	// repeats of the message are suppressed.
+	TTL time.Duration
+
+	// SweepInterval is how often the store is checked for elapsed windows
+	// with suppressed occurrences in order to send a follow-up message. A
+	// negative value disables the background check; Sweep may then be
+	// called directly. Defaults to half of the TTL.
+	SweepInterval time.Duration
+
+	// Store tracks message fingerprints. Defaults to a new
+	// MemoryFingerprintStore.
+	Store FingerprintStore
+
+	// SendTimeout is how long sending a follow-up message may take.
+	SendTimeout time.Duration
+}
+
+// Deduplicator wraps a TeamsClient in order to suppress repeats of a message
+// sent to the same webhook URL within a period of time. Once the period
+// elapses, a single follow-up message is sent noting how many times the
+// message occurred.
+//
+// Close should be called to stop the background check for elapsed windows
+// once the Deduplicator is no longer needed.
+type Deduplicator struct {
+	client *TeamsClient
+	config DedupConfig
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewDeduplicator creates a Deduplicator which sends messages using the
+// given TeamsClient.
+func NewDeduplicator(client *TeamsClient, config DedupConfig) *Deduplicator {
+	if config.TTL <= 0 {
+		config.TTL = DefaultDedupTTL
+	}
+
+	if config.SweepInterval == 0 {
+		config.SweepInterval = config.TTL / 2
+	}
+
+	if config.Store == nil {
+		config.Store = NewMemoryFingerprintStore()
+	}
+
+	if config.SendTimeout <= 0 {
+		config.SendTimeout = DefaultWebhookSendTimeout
+	}
+
+	d := Deduplicator{
+		client: client,
+		config: config,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if config.SweepInterval > 0 {
+		go d.sweeper()
+	} else {
+		close(d.done)
+	}
+
+	return &d
+}
+
+// SendWithContext submits the given message unless an identical message was
+// sent to the same webhook URL within the deduplication window. Messages are
+// identified by a hash of their prepared JSON payload. A suppressed message
+// is not considered an error.
+func (d *Deduplicator) SendWithContext(ctx context.Context, webhookURL string, message Message) error {
+	return d.SendWithKey(ctx, "", webhookURL, message)
+}
+
+// SendWithKey submits the given message unless a message with the same key
+// was sent to the same webhook URL within the deduplication window. If the
+// key is empty, a hash of the prepared JSON payload is used instead. A
+// suppressed message is not considered an error.
+func (d *Deduplicator) SendWithKey(ctx context.Context, key string, webhookURL string, message Message) error {
+	return d.send(ctx, key, webhookURL, message, nil)
+}
+
+// SendWithOptions submits the given message in the same manner as
+// SendWithKey, using the key given by SendIdempotencyKey (if any). The given
+// options are applied when submitting the message; see
+// TeamsClient.SendWithOptions.
+func (d *Deduplicator) SendWithOptions(ctx context.Context, webhookURL string, message Message, opts ...SendOption) error {
+	return d.send(ctx, newSendOptions(opts).idempotencyKey, webhookURL, message, opts)
+}
+
+// send submits the given message using the given options unless a message
+// with the same key was sent to the same webhook URL within the
+// deduplication window.
+func (d *Deduplicator) send(ctx context.Context, key string, webhookURL string, message Message, opts []SendOption) error {
+	fingerprint, err := d.fingerprint(key, webhookURL, message)
+	if err != nil {
+		return err
+	}
+
+	record := FingerprintRecord{
+		Fingerprint: fingerprint,
+		WebhookURL:  webhookURL,
+		Description: describeMessage(message),
+		Since:       d.client.Clock().Now(),
+	}
+
+	suppress, replaced, err := d.config.Store.Observe(record, d.config.TTL)
+	if err != nil {
+		return fmt.Errorf("failed to record message fingerprint: %w", err)
+	}
+
+	if suppress {
+		d.client.Logger().Debug("suppressed repeated message", "fingerprint", fingerprint)
+
+		return nil
+	}
+
+	if replaced != nil {
+		d.followUp(ctx, *replaced)
+	}
+
+	if err := d.client.SendWithOptions(ctx, webhookURL, message, opts...); err != nil {
+		// Allow the next occurrence to be sent since this one was not,
+		// unless repeats have been suppressed in the meantime; those are
+		// reported once the window elapses.
+		if removeErr := d.config.Store.Remove(record); removeErr != nil {
+			d.client.Logger().Warn("failed to remove message fingerprint", "error", removeErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Sweep sends a follow-up message for each elapsed deduplication window with
+// suppressed occurrences.
+func (d *Deduplicator) Sweep(ctx context.Context) error {
+	expired, err := d.config.Store.Expire(d.client.Clock().Now(), d.config.TTL)
+	if err != nil {
+		return fmt.Errorf("failed to expire message fingerprints: %w", err)
+	}
+
+	for _, record := range expired {
+		d.followUp(ctx, record)
+	}
+
+	return nil
+}
+
+// Close stops the background check for elapsed windows.
+func (d *Deduplicator) Close() {
+	d.once.Do(func() {
+		close(d.stop)
+	})
+	<-d.done
+}
+
+// sweeper periodically calls Sweep until the Deduplicator is closed.
+func (d *Deduplicator) sweeper() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.config.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), d.config.SendTimeout)
+			if err := d.Sweep(ctx); err != nil {
+				d.client.Logger().Error("failed to sweep message fingerprints", "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// followUp sends a message noting the number of occurrences of a message
+// since it was last sent.
+func (d *Deduplicator) followUp(ctx context.Context, record FingerprintRecord) {
+	if record.Suppressed == 0 {
+		return
+	}
+
+	card := messagecard.NewMessageCard()
+	card.Title = "Repeated message"
+	if record.Description != "" {
+		card.Title = fmt.Sprintf("Repeated: %s", record.Description)
+	}
+	card.Text = fmt.Sprintf(
+		"Occurred %d times since %s.",
+		record.Suppressed+1,
+		record.Since.Format("15:04"),
+	)
+
+	if err := d.client.SendWithContext(ctx, record.WebhookURL, card); err != nil {
+		d.client.Logger().Error("failed to send follow-up message", "error", err)
+	}
+}
+
+// fingerprint returns the fingerprint for a message sent to the given
+// webhook URL using the given key, or a canonical hash of the prepared
+// payload if the key is empty.
+func (d *Deduplicator) fingerprint(key string, webhookURL string, message Message) (string, error) {
+	if key == "" {
+		if err := message.Validate(); err != nil {
+			return "", fmt.Errorf(
+				"failed to validate message: %w",
+				err,
+			)
+		}
+
+		snapshot, err := snapshotMessage(message)
+		if err != nil {
+			return "", fmt.Errorf(
+				"failed to prepare message: %w",
+				err,
+			)
+		}
+
+		payload, err := preparedPayload(snapshot)
+		if err != nil {
+			return "", fmt.Errorf(
+				"failed to retrieve prepared message: %w",
+				err,
+			)
+		}
+
+		key, err = canonicalJSON(payload)
+		if err != nil {
+			return "", fmt.Errorf(
+				"failed to fingerprint message: %w",
+				err,
+			)
+		}
+	}
+
+	sum := sha256.Sum256([]byte(webhookURL + "\x00" + key))
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalJSON returns a canonical form of the given JSON payload with
+// object keys sorted and insignificant whitespace removed.
+func canonicalJSON(payload []byte) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return "", err
+	}
+
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(canonical), nil
+}
+
+// describeMessage returns a brief description of the given message for use
+// in follow-up messages.
+func describeMessage(message Message) string {
+	var candidates []string
+
+	switch m := message.(type) {
+	case *messagecard.MessageCard:
+		candidates = []string{m.Title, m.Summary, m.Text}
+	case *MessageCard:
+		candidates = []string{m.Title, m.Summary, m.Text}
+	case *botapi.Message:
+		candidates = []string{m.Text}
+	}
+
+	for _, candidate := range candidates {
+		if candidate = strings.TrimSpace(candidate); candidate != "" {
+			if i := strings.IndexAny(candidate, "\r\n"); i >= 0 {
+				candidate = candidate[:i]
+			}
+
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// MemoryFingerprintStore is a FingerprintStore which keeps records in
+// memory. It is safe for concurrent use within a single process.
+type MemoryFingerprintStore struct {
+	mu      sync.Mutex
+	records map[string]FingerprintRecord
+}
+
+// NewMemoryFingerprintStore creates an empty MemoryFingerprintStore.
+func NewMemoryFingerprintStore() *MemoryFingerprintStore {
+	return &MemoryFingerprintStore{
+		records: make(map[string]FingerprintRecord),
+	}
+}
+
+// Observe records an occurrence of the message described by the given
+// record.
+func (s *MemoryFingerprintStore) Observe(record FingerprintRecord, ttl time.Duration) (bool, *FingerprintRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	suppress, replaced := observeFingerprint(s.records, record, ttl)
+
+	return suppress, replaced, nil
+}
+
+// Expire removes all records whose window has elapsed.
+func (s *MemoryFingerprintStore) Expire(now time.Time, ttl time.Duration) ([]FingerprintRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return expireFingerprints(s.records, now, ttl), nil
+}
+
+// Remove removes the given record if no occurrences have been suppressed
+// since it was stored.
+func (s *MemoryFingerprintStore) Remove(record FingerprintRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removeFingerprint(s.records, record)
+
+	return nil
+}
+
+// FileFingerprintStore is a FingerprintStore which keeps records in a file
+// within a directory. A lock file is used to coordinate access so that
+// multiple processes on the same host may share the store.
+//
+// Records include the full webhook URL; the directory should be protected
+// accordingly.
+type FileFingerprintStore struct {
+	dir string
+}
+
+// NewFileFingerprintStore creates a FileFingerprintStore using the given
+// directory, creating it if needed.
+func NewFileFingerprintStore(dir string) (*FileFingerprintStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create fingerprint store directory: %w", err)
+	}
+
+	return &FileFingerprintStore{dir: dir}, nil
+}
+
+// Observe records an occurrence of the message described by the given
+// record.
+func (s *FileFingerprintStore) Observe(record FingerprintRecord, ttl time.Duration) (bool, *FingerprintRecord, error) {
+	var suppress bool
+	var replaced *FingerprintRecord
+
+	err := s.update(func(records map[string]FingerprintRecord) {
+		suppress, replaced = observeFingerprint(records, record, ttl)
+	})
+
+	return suppress, replaced, err
+}
+
+// Expire removes all records whose window has elapsed.
+func (s *FileFingerprintStore) Expire(now time.Time, ttl time.Duration) ([]FingerprintRecord, error) {
+	var expired []FingerprintRecord
+
+	err := s.update(func(records map[string]FingerprintRecord) {
+		expired = expireFingerprints(records, now, ttl)
+	})
+
+	return expired, err
+}
+
+// Remove removes the given record if no occurrences have been suppressed
+// since it was stored.
+func (s *FileFingerprintStore) Remove(record FingerprintRecord) error {
+	return s.update(func(records map[string]FingerprintRecord) {
+		removeFingerprint(records, record)
+	})
+}
+
+// update applies the given function to the stored records while holding
+// the store lock, then persists the result.
+func (s *FileFingerprintStore) update(fn func(records map[string]FingerprintRecord)) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path := filepath.Join(s.dir, fileFingerprintStoreName)
+	records := make(map[string]FingerprintRecord)
+
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to read fingerprint store: %w", err)
+	case len(data) > 0:
+		if err := json.Unmarshal(data, &records); err != nil {
+			packageLogger.Warn("discarding unreadable fingerprint records", "error", err)
+			records = make(map[string]FingerprintRecord)
+		}
+	}
+
+	fn(records)
+
+	data, err = json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to encode fingerprint store: %w", err)
+	}
+
+	// Write to a temporary file first so that readers never observe a
+	// partially written store.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write fingerprint store: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write fingerprint store: %w", err)
+	}
+
+	return nil
+}
+
+// lock acquires the store lock, returning a function which releases it.
+func (s *FileFingerprintStore) lock() (func(), error) {
+	path := filepath.Join(s.dir, fileFingerprintStoreLockName)
+	token := fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+	deadline := time.Now().Add(fileFingerprintStoreLockTimeout)
+
+	for {
+		err := createLockFile(path, token)
+		if err == nil {
+			return func() {
+				releaseLockFile(path, token)
+			}, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock fingerprint store: %w", err)
+		}
+
+		// Break locks left behind by a process that exited unexpectedly.
+		if isStaleLockFile(path) && breakStaleLockFile(path, token) {
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, ErrFingerprintStoreLocked
+		}
+
+		time.Sleep(fileFingerprintStoreLockPoll)
+	}
+}
+
+// createLockFile creates the lock file at the given path containing the
+// given token which identifies the owner. An error matching os.ErrExist is
+// returned if the lock file already exists.
+func createLockFile(path string, token string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteString(token)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(path)
+	}
+
+	return err
+}
+
+// releaseLockFile removes the lock file at the given path if it is still
+// owned by the given token. A lock file broken as stale and since created by
+// another process is left in place.
+func releaseLockFile(path string, token string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil || string(data) != token {
+		packageLogger.Warn("fingerprint store lock file no longer held", "path", path)
+		return
+	}
+
+	if err := os.Remove(path); err != nil {
+		packageLogger.Warn("failed to remove fingerprint store lock file", "error", err)
+	}
+}
+
+// isStaleLockFile indicates whether the lock file at the given path is old
+// enough to have been left behind by a process that exited unexpectedly.
+func isStaleLockFile(path string) bool {
+	info, err := os.Stat(path)
+
+	return err == nil && time.Since(info.ModTime()) > fileFingerprintStoreStaleLock
+}
+
+// breakStaleLockFile removes the lock file at the given path if it is stale,
+// indicating whether it was removed. Processes breaking the lock are
+// serialized using a second lock file and the lock file is checked again
+// once that is held, so that a lock created by another process after the
+// stale lock was removed is not also removed.
+func breakStaleLockFile(path string, token string) bool {
+	breakPath := path + ".break"
+
+	if err := createLockFile(breakPath, token); err != nil {
+		// The second lock file is only held briefly, so a stale one was
+		// left behind by a process that exited while breaking the lock.
+		if os.IsExist(err) && isStaleLockFile(breakPath) {
+			_ = os.Remove(breakPath)
+		}
+
+		return false
+	}
+	defer releaseLockFile(breakPath, token)
+
+	if !isStaleLockFile(path) {
+		return false
+	}
+
+	packageLogger.Warn("removing stale fingerprint store lock file", "path", path)
+
+	if err := os.Remove(path); err != nil {
+		packageLogger.Warn("failed to remove stale fingerprint store lock file", "error", err)
+		return false
+	}
+
+	return true
+}
+
+// observeFingerprint applies an occurrence of the message described by the
+// given record to a collection of records.
+func observeFingerprint(records map[string]FingerprintRecord, record FingerprintRecord, ttl time.Duration) (bool, *FingerprintRecord) {
+	existing, ok := records[record.Fingerprint]
+	if ok && record.Since.Before(existing.Since.Add(ttl)) {
+		existing.Suppressed++
+		records[record.Fingerprint] = existing
+
+		return true, nil
+	}
+
+	records[record.Fingerprint] = record
+
+	if ok && existing.Suppressed > 0 {
+		return false, &existing
+	}
+
+	return false, nil
+}
+
+// removeFingerprint removes the given record from a collection if it has
+// not been replaced and no occurrences have been suppressed.
+func removeFingerprint(records map[string]FingerprintRecord, record FingerprintRecord) {
+	existing, ok := records[record.Fingerprint]
+	if ok && existing.Since.Equal(record.Since) && existing.Suppressed == 0 {
+		delete(records, record.Fingerprint)
+	}
+}
+
+// expireFingerprints removes all records from a collection whose window has
+// elapsed, returning those with suppressed occurrences.
+func expireFingerprints(records map[string]FingerprintRecord, now time.Time, ttl time.Duration) []FingerprintRecord {
+	var expired []FingerprintRecord
+
+	for fingerprint, record := range records {
+		if now.Before(record.Since.Add(ttl)) {
+			continue
+		}
+
+		delete(records, fingerprint)
+
+		if record.Suppressed > 0 {
+			expired = append(expired, record)
+		}
+	}
+
+	return expired
+}
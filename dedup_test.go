@@ -0,0 +1,296 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeduplicator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	requireNoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fileStore, err := NewFileFingerprintStore(dir)
+	requireNoError(t, err)
+
+	stores := map[string]FingerprintStore{
+		"memory": NewMemoryFingerprintStore(),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		store := store
+
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			var received []messagecard.MessageCard
+
+			clock := &fakeClock{now: time.Date(2022, 3, 1, 9, 30, 0, 0, time.UTC)}
+			client := newRecordingTestClient(&mu, &received).SetClock(clock)
+
+			dedup := NewDeduplicator(client, DedupConfig{
+				TTL:           time.Minute,
+				SweepInterval: -1,
+				Store:         store,
+			})
+			defer dedup.Close()
+
+			webhookURL := "https://outlook.office.com/webhook/xxx"
+			otherWebhookURL := "https://outlook.office.com/webhook/yyy"
+			ctx := context.Background()
+
+			newCard := func() *messagecard.MessageCard {
+				card := messagecard.NewMessageCard()
+				card.Title = "Backup failed"
+				card.Text = "Disk full"
+				return card
+			}
+
+			for i := 0; i < 3; i++ {
+				assert.NoError(t, dedup.SendWithContext(ctx, webhookURL, newCard()))
+				clock.now = clock.now.Add(10 * time.Second)
+			}
+
+			// The same message sent to a different webhook is not a repeat.
+			assert.NoError(t, dedup.SendWithContext(ctx, otherWebhookURL, newCard()))
+
+			// Caller-supplied keys take precedence over message content.
+			different := newCard()
+			different.Text = "Disk still full"
+			assert.NoError(t, dedup.SendWithKey(ctx, "backup", webhookURL, newCard()))
+			assert.NoError(t, dedup.SendWithKey(ctx, "backup", webhookURL, different))
+
+			mu.Lock()
+			assert.Len(t, received, 3)
+			mu.Unlock()
+
+			clock.now = clock.now.Add(time.Minute)
+			assert.NoError(t, dedup.Sweep(ctx))
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if assert.Len(t, received, 5) {
+				var followUps []string
+				for _, card := range received[3:] {
+					assert.Equal(t, "Repeated: Backup failed", card.Title)
+					followUps = append(followUps, card.Text)
+				}
+
+				assert.ElementsMatch(t, []string{
+					"Occurred 3 times since 09:30.",
+					"Occurred 2 times since 09:30.",
+				}, followUps)
+			}
+		})
+	}
+}
+
+func TestFileFingerprintStoreShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	requireNoError(t, err)
+	defer os.RemoveAll(dir)
+
+	first, err := NewFileFingerprintStore(dir)
+	requireNoError(t, err)
+	second, err := NewFileFingerprintStore(dir)
+	requireNoError(t, err)
+
+	record := FingerprintRecord{
+		Fingerprint: "abc",
+		WebhookURL:  "https://outlook.office.com/webhook/xxx",
+		Since:       time.Now(),
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var sent int
+
+	for i := 0; i < 10; i++ {
+		store := first
+		if i%2 == 1 {
+			store = second
+		}
+
+		wg.Add(1)
+		go func(store *FileFingerprintStore) {
+			defer wg.Done()
+
+			suppress, _, err := store.Observe(record, time.Hour)
+			assert.NoError(t, err)
+
+			if !suppress {
+				mu.Lock()
+				sent++
+				mu.Unlock()
+			}
+		}(store)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, sent)
+
+	expired, err := second.Expire(time.Now().Add(2*time.Hour), time.Hour)
+	assert.NoError(t, err)
+	if assert.Len(t, expired, 1) {
+		assert.Equal(t, 9, expired[0].Suppressed)
+	}
+}
+
+func TestFileFingerprintStoreStaleLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	requireNoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileFingerprintStore(dir)
+	requireNoError(t, err)
+
+	// Leave behind a lock file as if a process exited while holding it.
+	path := filepath.Join(dir, fileFingerprintStoreLockName)
+	requireNoError(t, ioutil.WriteFile(path, []byte("exited"), 0600))
+	stale := time.Now().Add(-2 * fileFingerprintStoreStaleLock)
+	requireNoError(t, os.Chtimes(path, stale, stale))
+
+	record := FingerprintRecord{
+		Fingerprint: "abc",
+		WebhookURL:  "https://outlook.office.com/webhook/xxx",
+		Since:       time.Now(),
+	}
+
+	// The stale lock is broken once; access remains exclusive.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var sent int
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			suppress, _, err := store.Observe(record, time.Hour)
+			assert.NoError(t, err)
+
+			if !suppress {
+				mu.Lock()
+				sent++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, sent)
+
+	// A lock which has since been taken by another process is not released.
+	unlock, err := store.lock()
+	requireNoError(t, err)
+	requireNoError(t, ioutil.WriteFile(path, []byte("other"), 0600))
+	unlock()
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", string(data))
+}
+
+func TestDeduplicatorFailedSendKeepsSuppressed(t *testing.T) {
+	var mu sync.Mutex
+	var received []messagecard.MessageCard
+
+	clock := &fakeClock{now: time.Date(2022, 3, 1, 9, 30, 0, 0, time.UTC)}
+	client := newRecordingTestClient(&mu, &received).SetClock(clock)
+
+	var dedup *Deduplicator
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+	ctx := context.Background()
+
+	newCard := func() *messagecard.MessageCard {
+		card := messagecard.NewMessageCard()
+		card.Title = "Backup failed"
+		card.Text = "Disk full"
+		return card
+	}
+
+	// A repeat is suppressed while the first submission is in progress,
+	// which then fails.
+	var calls int
+	client = client.With(WithInterceptors(func(req *SendRequest, next SendHandler) (*http.Response, error) {
+		calls++
+		if calls > 1 {
+			return next(req)
+		}
+
+		assert.NoError(t, dedup.SendWithContext(ctx, webhookURL, newCard()))
+
+		return nil, errors.New("connection reset by peer")
+	}))
+
+	dedup = NewDeduplicator(client, DedupConfig{TTL: time.Minute, SweepInterval: -1})
+	defer dedup.Close()
+
+	assert.Error(t, dedup.SendWithContext(ctx, webhookURL, newCard()))
+
+	// The suppressed repeat is reported once the window elapses.
+	clock.now = clock.now.Add(time.Minute)
+	assert.NoError(t, dedup.Sweep(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, "Occurred 2 times since 09:30.", received[0].Text)
+	}
+}
+
+func TestFingerprintStoreRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	requireNoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fileStore, err := NewFileFingerprintStore(dir)
+	requireNoError(t, err)
+
+	stores := map[string]FingerprintStore{
+		"memory": NewMemoryFingerprintStore(),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		now := time.Date(2022, 3, 1, 9, 30, 0, 0, time.UTC)
+		first := FingerprintRecord{Fingerprint: "abc", Since: now}
+		second := FingerprintRecord{Fingerprint: "def", Since: now}
+
+		for _, record := range []FingerprintRecord{first, second, second} {
+			_, _, err := store.Observe(record, time.Minute)
+			requireNoError(t, err)
+		}
+
+		// Records with suppressed occurrences or replaced records are kept.
+		assert.NoError(t, store.Remove(first), name)
+		assert.NoError(t, store.Remove(second), name)
+		assert.NoError(t, store.Remove(FingerprintRecord{Fingerprint: "def", Since: now.Add(time.Second)}), name)
+
+		expired, err := store.Expire(now.Add(time.Minute), time.Minute)
+		assert.NoError(t, err, name)
+		if assert.Len(t, expired, 1, name) {
+			assert.Equal(t, "def", expired[0].Fingerprint, name)
+			assert.Equal(t, 1, expired[0].Suppressed, name)
+		}
+	}
+}
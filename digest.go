@@ -0,0 +1,462 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+)
+
+// Default settings applied by a DigestSender unless overridden.
+const (
+	// DefaultDigestWindow is the default period of time that messages are
+	// collected before a digest is sent.
+	DefaultDigestWindow = 30 * time.Second
+
+	// DefaultDigestMaxEntries is the default maximum number of messages
+	// included in a single digest.
+	DefaultDigestMaxEntries int = 10
+)
+
+// digestTimeFormat is the format used to display the time each message was
+// received in a digest.
+const digestTimeFormat = "15:04:05"
+
+// digestSummaryTextLimit is the maximum length of the text displayed for
+// each message included in a summary digest.
+const digestSummaryTextLimit int = 200
+
+// ErrDigestClosed is returned when a message is added to a closed
+// DigestSender.
+var ErrDigestClosed = errors.New("digest sender is closed")
+
+// DigestMode determines the format of a digest.
+type DigestMode int
+
+const (
+	// DigestSections includes one section per original message in the
+	// digest.
+	DigestSections DigestMode = iota
+
+	// DigestSummary includes a count of the original messages along with a
+	// brief list of the first entries.
+	DigestSummary
+)
+
+// DigestOverflowPolicy determines how messages are handled which exceed the
+// maximum number of entries allowed in a digest.
+type DigestOverflowPolicy int
+
+const (
+	// DigestOverflowSummarize omits the excess messages from the digest,
+	// noting how many were omitted.
+	DigestOverflowSummarize DigestOverflowPolicy = iota
+
+	// DigestOverflowSendSeparately sends each excess message on its own
+	// after the digest.
+	DigestOverflowSendSeparately
+
+	// DigestOverflowCarryOver holds the excess messages for the next digest.
+	DigestOverflowCarryOver
+)
+
+// DigestConfig provides settings for a DigestSender. Default values are used
+// for any zero value fields.
+type DigestConfig struct {
+	// Window is the period of time after the first message is added that a
+	// digest is sent.
+	Window time.Duration
+
+	// MaxMessages is the number of messages which results in a digest being
+	// sent before the window elapses. A zero value indicates that digests
+	// are only sent once the window elapses.
+	MaxMessages int
+
+	// MaxEntries is the maximum number of messages included in a digest.
+	MaxEntries int
+
+	// Mode determines the format of the digest.
+	Mode DigestMode
+
+	// Overflow determines how messages exceeding MaxEntries are handled.
+	Overflow DigestOverflowPolicy
+
+	// Title is used as the title of each digest along with the number of
+	// messages included.
+	Title string
+
+	// ThemeColor is the theme color of each digest. If not set, the theme
+	// color of the first message is used.
+	ThemeColor string
+
+	// SendTimeout is how long sending each digest may take, including any
+	// retry attempts applied by the client.
+	SendTimeout time.Duration
+
+	// OnDelivery is called with the outcome of sending each digest (or
+	// individual message).
+	OnDelivery DeliveryFunc
+}
+
+// digestItem is a message collected for a digest.
+type digestItem struct {
+	card     *messagecard.MessageCard
+	received time.Time
+}
+
+// digestBatch is the set of messages collected for a webhook URL.
+type digestBatch struct {
+	items []digestItem
+	timer *time.Timer
+}
+
+// DigestSender collects messages for each webhook URL over a period of time
+// and sends them as a single combined message (a "digest") in order to avoid
+// flooding a channel with a burst of similar messages.
+//
+// Close should be called to send any remaining messages once the
+// DigestSender is no longer needed.
+type DigestSender struct {
+	client *TeamsClient
+	config DigestConfig
+
+	mu      sync.Mutex
+	batches map[string]*digestBatch
+	closed  bool
+
+	// inflight is the number of digests being sent; idle is signaled once
+	// it reaches zero.
+	inflight int
+	idle     *sync.Cond
+}
+
+// NewDigestSender creates a DigestSender which sends digests using the given
+// TeamsClient.
+func NewDigestSender(client *TeamsClient, config DigestConfig) *DigestSender {
+	if config.Window <= 0 {
+		config.Window = DefaultDigestWindow
+	}
+
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultDigestMaxEntries
+	}
+
+	if config.SendTimeout <= 0 {
+		config.SendTimeout = DefaultWebhookSendTimeout
+	}
+
+	d := DigestSender{
+		client:  client,
+		config:  config,
+		batches: make(map[string]*digestBatch),
+	}
+	d.idle = sync.NewCond(&d.mu)
+
+	return &d
+}
+
+// Add validates the given message and collects it for the next digest sent
+// to the given webhook URL. A copy of the message is collected; later
+// changes to the message do not affect the digest.
+func (d *DigestSender) Add(webhookURL string, card *messagecard.MessageCard) error {
+	if err := d.client.ValidateWebhook(webhookURL); err != nil {
+		return fmt.Errorf(
+			"failed to validate webhook URL: %w",
+			err,
+		)
+	}
+
+	if err := card.Validate(); err != nil {
+		return fmt.Errorf(
+			"failed to validate message: %w",
+			err,
+		)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return ErrDigestClosed
+	}
+
+	batch := d.batch(webhookURL)
+	batch.items = append(batch.items, digestItem{
+		card:     card.Clone(),
+		received: time.Now(),
+	})
+
+	if d.config.MaxMessages > 0 && len(batch.items) >= d.config.MaxMessages {
+		d.dispatch(webhookURL)
+	}
+
+	return nil
+}
+
+// Flush sends a digest for each webhook URL with collected messages and
+// blocks until they are sent or the given context is cancelled, whichever
+// occurs first.
+func (d *DigestSender) Flush(ctx context.Context) error {
+	d.mu.Lock()
+	for webhookURL := range d.batches {
+		d.dispatch(webhookURL)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.mu.Lock()
+		for d.inflight > 0 {
+			d.idle.Wait()
+		}
+		d.mu.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
+// Close stops accepting new messages and sends any collected messages,
+// blocking until they are sent or the given context is cancelled.
+func (d *DigestSender) Close(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	// Messages carried over from a digest sent during the flush are sent
+	// immediately once the sender is closed.
+	for {
+		if err := d.Flush(ctx); err != nil {
+			return err
+		}
+
+		d.mu.Lock()
+		remaining := len(d.batches)
+		d.mu.Unlock()
+
+		if remaining == 0 {
+			return nil
+		}
+	}
+}
+
+// batch returns the batch for the given webhook URL, creating one (and
+// starting its window) if needed. The caller must hold the lock.
+func (d *DigestSender) batch(webhookURL string) *digestBatch {
+	batch, ok := d.batches[webhookURL]
+	if !ok {
+		batch = &digestBatch{}
+		batch.timer = time.AfterFunc(d.config.Window, func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+
+			if d.batches[webhookURL] == batch {
+				d.dispatch(webhookURL)
+			}
+		})
+		d.batches[webhookURL] = batch
+	}
+
+	return batch
+}
+
+// dispatch removes the batch for the given webhook URL and sends it in the
+// background. Messages carried over are added to a new batch. The caller
+// must hold the lock.
+func (d *DigestSender) dispatch(webhookURL string) {
+	batch, ok := d.batches[webhookURL]
+	if !ok {
+		return
+	}
+
+	batch.timer.Stop()
+	delete(d.batches, webhookURL)
+
+	items := batch.items
+	var separate []digestItem
+
+	if len(items) > d.config.MaxEntries {
+		switch d.config.Overflow {
+		case DigestOverflowSendSeparately:
+			separate = items[d.config.MaxEntries:]
+			items = items[:d.config.MaxEntries]
+
+		case DigestOverflowCarryOver:
+			carried := d.batch(webhookURL)
+			carried.items = append(carried.items, items[d.config.MaxEntries:]...)
+			items = items[:d.config.MaxEntries]
+		}
+	}
+
+	d.inflight++
+	go func() {
+		defer func() {
+			d.mu.Lock()
+			d.inflight--
+			if d.inflight == 0 {
+				d.idle.Broadcast()
+			}
+			d.mu.Unlock()
+		}()
+
+		d.send(webhookURL, d.digest(items), items[0].received)
+
+		for _, item := range separate {
+			d.send(webhookURL, item.card, item.received)
+		}
+	}()
+}
+
+// send submits a message to the given webhook URL and reports the outcome.
+func (d *DigestSender) send(webhookURL string, card *messagecard.MessageCard, received time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.SendTimeout)
+	defer cancel()
+
+	err := d.client.SendWithContext(ctx, webhookURL, card)
+	if err != nil {
+		d.client.Logger().Error(
+			"failed to send digest",
+			"webhook", d.client.loggedWebhookURL(webhookURL),
+			"error", err,
+		)
+	}
+
+	if d.config.OnDelivery != nil {
+		d.config.OnDelivery(Delivery{
+			WebhookURL: webhookURL,
+			Message:    card,
+			Err:        err,
+			Enqueued:   received,
+		})
+	}
+}
+
+// digest combines the given messages into a single message. A single message
+// is returned as-is.
+func (d *DigestSender) digest(items []digestItem) *messagecard.MessageCard {
+	if len(items) == 1 {
+		return items[0].card
+	}
+
+	shown := items
+	if len(shown) > d.config.MaxEntries {
+		shown = shown[:d.config.MaxEntries]
+	}
+	omitted := len(items) - len(shown)
+
+	card := messagecard.NewMessageCard()
+	card.Title = fmt.Sprintf("%d messages", len(items))
+	if d.config.Title != "" {
+		card.Title = fmt.Sprintf("%s (%d messages)", d.config.Title, len(items))
+	}
+	card.Summary = card.Title
+
+	card.ThemeColor = d.config.ThemeColor
+	if card.ThemeColor == "" {
+		card.ThemeColor = items[0].card.ThemeColor
+	}
+
+	switch d.config.Mode {
+	case DigestSummary:
+		lines := make([]string, 0, len(shown)+1)
+		lines = append(lines, fmt.Sprintf(
+			"Received %d messages between %s and %s:",
+			len(items),
+			items[0].received.Format(digestTimeFormat),
+			items[len(items)-1].received.Format(digestTimeFormat),
+		))
+
+		for _, item := range shown {
+			lines = append(lines, fmt.Sprintf(
+				"- **%s** %s",
+				item.received.Format(digestTimeFormat),
+				digestSummaryText(item.card),
+			))
+		}
+
+		if omitted > 0 {
+			lines = append(lines, fmt.Sprintf("- ... and %d more", omitted))
+		}
+
+		card.Text = strings.Join(lines, "\n\n")
+
+	default:
+		for _, item := range shown {
+			card.Sections = append(card.Sections, digestSection(item))
+		}
+
+		if omitted > 0 {
+			card.Text = fmt.Sprintf("%d additional messages not shown.", omitted)
+		}
+	}
+
+	return card
+}
+
+// digestSection converts a message into a section for inclusion in a
+// digest.
+func digestSection(item digestItem) *messagecard.Section {
+	section := messagecard.NewSection()
+	section.StartGroup = true
+	section.ActivityTitle = item.card.Title
+	if section.ActivityTitle == "" {
+		section.ActivityTitle = item.card.Summary
+	}
+	section.ActivitySubtitle = item.received.Format(digestTimeFormat)
+	section.Text = item.card.Text
+
+	for _, s := range item.card.Sections {
+		if s == nil {
+			continue
+		}
+
+		if section.Text == "" {
+			section.Text = s.Text
+		}
+		section.Facts = append(section.Facts, s.Facts...)
+	}
+
+	return section
+}
+
+// digestSummaryText returns a brief, single line description of a message
+// for inclusion in a summary digest.
+func digestSummaryText(card *messagecard.MessageCard) string {
+	var parts []string
+	for _, s := range []string{card.Title, card.Text} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+
+	if len(parts) == 0 {
+		parts = append(parts, card.Summary)
+	}
+
+	text := strings.Join(parts, ": ")
+	if i := strings.IndexAny(text, "\r\n"); i >= 0 {
+		text = text[:i]
+	}
+
+	if runes := []rune(text); len(runes) > digestSummaryTextLimit {
+		text = string(runes[:digestSummaryTextLimit]) + "..."
+	}
+
+	return text
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+	"github.com/stretchr/testify/assert"
+)
+
+// newRecordingTestClient returns a TeamsClient which decodes each submitted
+// MessageCard into the given collection.
+func newRecordingTestClient(mu *sync.Mutex, received *[]messagecard.MessageCard) *TeamsClient {
+	httpClient := NewTestClient(func(req *http.Request) (*http.Response, error) {
+		var card messagecard.MessageCard
+		if err := json.NewDecoder(req.Body).Decode(&card); err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		*received = append(*received, card)
+		mu.Unlock()
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(ExpectedWebhookURLResponseText)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	return NewTeamsClient().SetHTTPClient(httpClient)
+}
+
+func TestDigestSender(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+
+	tests := []struct {
+		name      string
+		config    DigestConfig
+		messages  int
+		wantCards int
+		check     func(t *testing.T, cards []messagecard.MessageCard)
+	}{
+		{
+			name: "sections",
+			config: DigestConfig{
+				Window:      time.Hour,
+				MaxMessages: 3,
+				Title:       "Build failures",
+			},
+			messages:  3,
+			wantCards: 1,
+			check: func(t *testing.T, cards []messagecard.MessageCard) {
+				assert.Equal(t, "Build failures (3 messages)", cards[0].Title)
+				if assert.Len(t, cards[0].Sections, 3) {
+					assert.Equal(t, "alert 0", cards[0].Sections[0].ActivityTitle)
+					assert.Equal(t, "alert 2", cards[0].Sections[2].ActivityTitle)
+				}
+			},
+		},
+		{
+			name: "summary with omitted entries",
+			config: DigestConfig{
+				Window:     time.Hour,
+				MaxEntries: 2,
+				Mode:       DigestSummary,
+			},
+			messages:  5,
+			wantCards: 1,
+			check: func(t *testing.T, cards []messagecard.MessageCard) {
+				assert.Equal(t, "5 messages", cards[0].Title)
+				assert.Contains(t, cards[0].Text, "alert 1: details")
+				assert.NotContains(t, cards[0].Text, "alert 2")
+				assert.Contains(t, cards[0].Text, "and 3 more")
+			},
+		},
+		{
+			name: "overflow sent separately",
+			config: DigestConfig{
+				Window:     time.Hour,
+				MaxEntries: 2,
+				Overflow:   DigestOverflowSendSeparately,
+			},
+			messages:  3,
+			wantCards: 2,
+			check: func(t *testing.T, cards []messagecard.MessageCard) {
+				assert.Len(t, cards[0].Sections, 2)
+				assert.Equal(t, "alert 2", cards[1].Title)
+			},
+		},
+		{
+			name: "overflow carried over",
+			config: DigestConfig{
+				Window:     time.Hour,
+				MaxEntries: 2,
+				Overflow:   DigestOverflowCarryOver,
+			},
+			messages:  3,
+			wantCards: 2,
+			check: func(t *testing.T, cards []messagecard.MessageCard) {
+				assert.Len(t, cards[0].Sections, 2)
+				assert.Equal(t, "alert 2", cards[1].Title)
+			},
+		},
+		{
+			name: "window elapsed",
+			config: DigestConfig{
+				Window: 10 * time.Millisecond,
+			},
+			messages:  2,
+			wantCards: 1,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var received []messagecard.MessageCard
+
+			sender := NewDigestSender(newRecordingTestClient(&mu, &received), test.config)
+
+			for i := 0; i < test.messages; i++ {
+				card := messagecard.NewMessageCard()
+				card.Title = fmt.Sprintf("alert %d", i)
+				card.Text = "details"
+				assert.NoError(t, sender.Add(webhookURL, card))
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			// Digests are sent once the window elapses without a Flush.
+			if test.config.Window < time.Second {
+				deadline := time.Now().Add(5 * time.Second)
+				for {
+					mu.Lock()
+					n := len(received)
+					mu.Unlock()
+
+					if n >= test.wantCards || time.Now().After(deadline) {
+						break
+					}
+					time.Sleep(test.config.Window)
+				}
+
+				mu.Lock()
+				assert.Len(t, received, test.wantCards)
+				mu.Unlock()
+			}
+
+			assert.NoError(t, sender.Close(ctx))
+			late := messagecard.NewMessageCard()
+			late.Text = "too late"
+			assert.Equal(t, ErrDigestClosed, sender.Add(webhookURL, late))
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if assert.Len(t, received, test.wantCards) && test.check != nil {
+				test.check(t, received)
+			}
+		})
+	}
+}
+
+func TestDigestSenderCopiesMessages(t *testing.T) {
+	var mu sync.Mutex
+	var received []messagecard.MessageCard
+
+	sender := NewDigestSender(newRecordingTestClient(&mu, &received), DigestConfig{Window: time.Hour})
+
+	card := messagecard.NewMessageCard()
+	card.Title = "A"
+	card.Text = "first"
+	assert.NoError(t, sender.Add("https://outlook.office.com/webhook/xxx", card))
+
+	card.Title = "B"
+	card.Text = "second"
+	assert.NoError(t, sender.Add("https://outlook.office.com/webhook/xxx", card))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, sender.Close(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if assert.Len(t, received, 1) && assert.Len(t, received[0].Sections, 2) {
+		assert.Equal(t, "A", received[0].Sections[0].ActivityTitle)
+		assert.Equal(t, "first", received[0].Sections[0].Text)
+		assert.Equal(t, "B", received[0].Sections[1].ActivityTitle)
+	}
+}
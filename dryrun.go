@@ -0,0 +1,193 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dryRunFileTimeFormat is the timestamp format used for payload file names.
+const dryRunFileTimeFormat string = "20060102T150405.000000000Z"
+
+// DryRunConfig provides settings for a DryRun. Prepared payloads are written
+// to os.Stdout if neither Writer nor Dir is set.
+type DryRunConfig struct {
+	// Writer receives each prepared payload.
+	Writer io.Writer
+
+	// Dir is a directory which receives each prepared payload as a separate
+	// file.
+	Dir string
+
+	// StatusCode is the HTTP status code of the simulated response. If not
+	// set, http.StatusOK is used.
+	StatusCode int
+
+	// ResponseText is the body of the simulated response. If not set for a
+	// successful status code, ExpectedWebhookURLResponseText is used.
+	ResponseText string
+
+	// Header provides headers (e.g., Retry-After) for the simulated response.
+	Header http.Header
+
+	// Err is returned in place of a simulated response (e.g., to simulate a
+	// network error).
+	Err error
+}
+
+// DryRun replaces message submission for a TeamsClient so that messages go
+// through the full validation and preparation pipeline without being sent
+// to a webhook URL. Each prepared payload is written as indented JSON along
+// with the webhook URL (redacted) and a simulated response is returned. A
+// DryRun is safe for concurrent use and may be shared by multiple clients.
+type DryRun struct {
+	mu     sync.Mutex
+	config DryRunConfig
+	seq    int
+}
+
+// dryRunEntry is the JSON document written for each prepared payload.
+type dryRunEntry struct {
+	WebhookURL string          `json:"webhookURL"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// NewDryRun creates a DryRun using the given settings. If a directory is
+// specified it is created if needed.
+func NewDryRun(config DryRunConfig) (*DryRun, error) {
+	if config.Dir != "" {
+		if err := os.MkdirAll(config.Dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create dry run directory: %w", err)
+		}
+	}
+
+	if config.Writer == nil && config.Dir == "" {
+		config.Writer = os.Stdout
+	}
+
+	if config.StatusCode == 0 {
+		config.StatusCode = http.StatusOK
+	}
+
+	if config.ResponseText == "" && config.StatusCode < 300 {
+		config.ResponseText = ExpectedWebhookURLResponseText
+	}
+
+	return &DryRun{config: config}, nil
+}
+
+// SetDryRun accepts a DryRun which replaces submission of messages to the
+// webhook URL.
+//
+// Deprecated: use the WithDryRun option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetDryRun(dryRun *DryRun) *TeamsClient {
+	c.dryRun = dryRun
+
+	return c
+}
+
+// DryRun returns the configured DryRun for the client or nil if one has not
+// been set.
+//
+// Deprecated: use TeamsClient.DryRun() method instead.
+func (c *teamsClient) DryRun() *DryRun {
+	return nil
+}
+
+// DryRun returns the configured DryRun for the client or nil if one has not
+// been set.
+func (c *TeamsClient) DryRun() *DryRun {
+	return c.dryRun
+}
+
+// submit writes the payload of the given request and returns the simulated
+// response.
+func (d *DryRun) submit(req *http.Request) (*http.Response, error) {
+	var payload []byte
+	if req.Body != nil {
+		var err error
+		payload, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	if err := d.write(redactWebhookURL(req.URL.String()), payload); err != nil {
+		return nil, fmt.Errorf("failed to write dry run payload: %w", err)
+	}
+
+	if d.config.Err != nil {
+		return nil, d.config.Err
+	}
+
+	header := make(http.Header)
+	for k, v := range d.config.Header {
+		header[k] = append([]string(nil), v...)
+	}
+
+	return &http.Response{
+		StatusCode: d.config.StatusCode,
+		Status:     fmt.Sprintf("%d %s", d.config.StatusCode, http.StatusText(d.config.StatusCode)),
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(d.config.ResponseText)),
+		Request:    req,
+	}, nil
+}
+
+// write records the payload for the given webhook URL.
+func (d *DryRun) write(webhookURL string, payload []byte) error {
+	entry := dryRunEntry{
+		WebhookURL: webhookURL,
+		Payload:    payload,
+	}
+
+	// Record payloads which are not JSON as a string.
+	if !json.Valid(payload) {
+		quoted, err := json.Marshal(string(payload))
+		if err != nil {
+			return err
+		}
+		entry.Payload = quoted
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.seq++
+
+	if d.config.Dir != "" {
+		name := fmt.Sprintf("%s-%06d.json", time.Now().UTC().Format(dryRunFileTimeFormat), d.seq)
+		if err := ioutil.WriteFile(filepath.Join(d.config.Dir, name), data, 0600); err != nil {
+			return err
+		}
+	}
+
+	if d.config.Writer != nil {
+		if _, err := d.config.Writer.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeamsClientDryRun(t *testing.T) {
+	webhookURL := "https://example.webhook.office.com/webhookb2/secret-group@secret-tenant/IncomingWebhook/secret-id/secret-owner"
+
+	dir, err := ioutil.TempDir("", "dryrun")
+	requireNoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	dryRun, err := NewDryRun(DryRunConfig{Writer: &buf, Dir: dir})
+	requireNoError(t, err)
+
+	client := NewTeamsClient().
+		SetDryRun(dryRun).
+		SetHTTPClient(NewTestClient(func(req *http.Request) (*http.Response, error) {
+			t.Error("unexpected request to webhook URL")
+
+			return nil, errors.New("unexpected request")
+		}))
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	assert.NoError(t, client.SendWithContext(context.Background(), webhookURL, &msgCard))
+
+	output := buf.String()
+	assert.Contains(t, output, "{\n  \"webhookURL\": \"https://example.webhook.office.com/REDACTED\",\n  \"payload\": {\n")
+	assert.Contains(t, output, `"text": "Hello World"`)
+	assert.NotContains(t, output, "secret")
+
+	files, err := ioutil.ReadDir(dir)
+	requireNoError(t, err)
+	if assert.Len(t, files, 1) {
+		data, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+		requireNoError(t, err)
+		assert.Equal(t, output, string(data))
+	}
+
+	// Invalid messages are rejected before reaching the dry run.
+	buf.Reset()
+	assert.Error(t, client.SendWithContext(context.Background(), webhookURL, &MessageCard{}))
+	assert.Empty(t, buf.String())
+}
+
+func TestTeamsClientDryRunSimulatedErrors(t *testing.T) {
+	var buf bytes.Buffer
+	dryRun, err := NewDryRun(DryRunConfig{
+		Writer:     &buf,
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"3"}},
+	})
+	requireNoError(t, err)
+
+	clock := &fakeClock{now: time.Now()}
+	client := NewTeamsClient().
+		SetDryRun(dryRun).
+		SetRetryPolicy(NewConstantBackoff(1, time.Second)).
+		SetClock(clock)
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	err = client.SendWithContext(context.Background(), "https://outlook.office.com/webhook/xxx", &msgCard)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	assert.Equal(t, []time.Duration{3 * time.Second}, clock.sleeps)
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte(`"webhookURL"`)))
+
+	networkErr := errors.New("connection reset by peer")
+	dryRun, err = NewDryRun(DryRunConfig{Writer: ioutil.Discard, Err: networkErr})
+	requireNoError(t, err)
+
+	err = NewTeamsClient().
+		SetDryRun(dryRun).
+		SendWithContext(context.Background(), "https://outlook.office.com/webhook/xxx", &msgCard)
+	assert.True(t, errors.Is(err, networkErr))
+}
@@ -0,0 +1,199 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors used to classify a failed message submission. Use errors.Is with
+// an error returned from a send method to check for a specific cause.
+var (
+	// ErrRateLimited indicates that the remote endpoint throttled the
+	// message submission (HTTP 429).
+	ErrRateLimited = errors.New("rate limited by remote endpoint")
+
+	// ErrPayloadTooLarge indicates that the remote endpoint rejected the
+	// message due to its size (HTTP 413).
+	ErrPayloadTooLarge = errors.New("message payload too large")
+
+	// ErrWebhookNotFound indicates that the webhook URL is not known to the
+	// remote endpoint (HTTP 404 or 410).
+	ErrWebhookNotFound = errors.New("webhook not found")
+
+	// ErrConnectorRemoved indicates that the connector associated with the
+	// webhook URL has been removed (HTTP 410). An error matching
+	// ErrConnectorRemoved also matches ErrWebhookNotFound.
+	ErrConnectorRemoved = errors.New("webhook connector removed")
+
+	// ErrBadRequest indicates that the remote endpoint rejected the message
+	// (HTTP 4xx not otherwise classified), often due to a malformed or
+	// incomplete message.
+	ErrBadRequest = errors.New("message rejected by remote endpoint")
+
+	// ErrServerError indicates that the remote endpoint failed to process
+	// the message (HTTP 5xx).
+	ErrServerError = errors.New("remote endpoint server error")
+)
+
+// teamsReportedStatusRegex matches the HTTP status code reported in the
+// response text provided by Microsoft Teams along with a 200 status code
+// when message delivery fails (e.g., "Webhook message delivery failed with
+// error: Microsoft Teams endpoint returned HTTP error 429 with
+// ContextId ...").
+var teamsReportedStatusRegex = regexp.MustCompile(`HTTP error (\d{3})`)
+
+// connectorRemovedResponseTexts are fragments of response text provided by
+// Microsoft Teams when the connector for a webhook URL has been removed.
+var connectorRemovedResponseTexts = []string{
+	"connector has been removed",
+	"connectorconfiguration not found",
+	"connector configuration not found",
+}
+
+// SendError is returned when a message submission fails after the request
+// has been prepared, either because the remote endpoint could not be reached
+// or because it indicated that the message was not delivered.
+//
+// Use errors.Is with ErrRateLimited, ErrPayloadTooLarge, ErrWebhookNotFound,
+// ErrConnectorRemoved, ErrBadRequest or ErrServerError to classify the
+// failure.
+type SendError struct {
+	// StatusCode is the HTTP status code returned by the remote endpoint, or
+	// zero if a response was not received.
+	StatusCode int
+
+	// Status is the HTTP status returned by the remote endpoint (e.g., "400
+	// Bad Request").
+	Status string
+
+	// ResponseText is the response body returned by the remote endpoint.
+	ResponseText string
+
+	// Host is the host portion of the webhook URL. The remainder of the URL
+	// is omitted as it contains secrets.
+	Host string
+
+	// Attempt is the number of the attempt which failed, starting at 1.
+	Attempt int
+
+	// Elapsed is the time elapsed since the initial attempt was made.
+	Elapsed time.Duration
+
+	// RetryAfter is the delay requested by the remote endpoint before
+	// another attempt is made, if provided.
+	RetryAfter time.Duration
+
+	// Err is the underlying cause, such as a transport error or
+	// ErrInvalidWebhookURLResponseText.
+	Err error
+}
+
+// Error provides a description of the failure.
+func (e *SendError) Error() string {
+	switch {
+	case errors.Is(e.Err, ErrInvalidWebhookURLResponseText):
+		return fmt.Sprintf(
+			"got %q, expected %q: %v",
+			e.ResponseText,
+			ExpectedWebhookURLResponseText,
+			e.Err,
+		)
+
+	case e.Err != nil:
+		return e.Err.Error()
+
+	default:
+		return fmt.Sprintf("error on notification: %v, %q", e.Status, e.ResponseText)
+	}
+}
+
+// Unwrap returns the underlying cause.
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Is indicates whether the failure matches the given classification error.
+func (e *SendError) Is(target error) bool {
+	class := e.Classification()
+	if class == nil {
+		return false
+	}
+
+	if target == class {
+		return true
+	}
+
+	return target == ErrWebhookNotFound && class == ErrConnectorRemoved
+}
+
+// Classification returns the error (e.g., ErrRateLimited) classifying the
+// failure, or nil if the failure is not classified (e.g., a network error).
+// Classification considers both the HTTP status code and any HTTP status
+// code reported in the response text provided along with a 200 status code.
+func (e *SendError) Classification() error {
+	if e.isConnectorRemovedText() {
+		return ErrConnectorRemoved
+	}
+
+	statusCode := e.EffectiveStatusCode()
+
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return ErrPayloadTooLarge
+	case statusCode == http.StatusNotFound:
+		return ErrWebhookNotFound
+	case statusCode == http.StatusGone:
+		return ErrConnectorRemoved
+	case statusCode >= http.StatusInternalServerError:
+		return ErrServerError
+	case statusCode >= http.StatusBadRequest:
+		return ErrBadRequest
+	default:
+		return nil
+	}
+}
+
+// EffectiveStatusCode returns the HTTP status code describing the failure.
+// If the remote endpoint returned a successful status code along with
+// response text reporting a different status code (as Microsoft Teams does
+// for some failures), the reported status code is returned.
+func (e *SendError) EffectiveStatusCode() int {
+	if e.StatusCode >= http.StatusMultipleChoices {
+		return e.StatusCode
+	}
+
+	if match := teamsReportedStatusRegex.FindStringSubmatch(e.ResponseText); match != nil {
+		if code, err := strconv.Atoi(match[1]); err == nil {
+			return code
+		}
+	}
+
+	return e.StatusCode
+}
+
+// isConnectorRemovedText indicates whether the response text reports that
+// the connector for the webhook URL has been removed.
+func (e *SendError) isConnectorRemovedText() bool {
+	text := strings.ToLower(e.ResponseText)
+	for _, fragment := range connectorRemovedResponseTexts {
+		if strings.Contains(text, fragment) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,164 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendErrorClassification(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCode   int
+		responseText string
+		want         error
+		retryable    bool
+	}{
+		{
+			name:       "throttled",
+			statusCode: http.StatusTooManyRequests,
+			want:       ErrRateLimited,
+			retryable:  true,
+		},
+		{
+			name:       "payload too large",
+			statusCode: http.StatusRequestEntityTooLarge,
+			want:       ErrPayloadTooLarge,
+		},
+		{
+			name:       "not found",
+			statusCode: http.StatusNotFound,
+			want:       ErrWebhookNotFound,
+		},
+		{
+			name:       "gone",
+			statusCode: http.StatusGone,
+			want:       ErrConnectorRemoved,
+		},
+		{
+			name:         "bad request",
+			statusCode:   http.StatusBadRequest,
+			responseText: "Summary or Text is required.",
+			want:         ErrBadRequest,
+		},
+		{
+			name:       "server error",
+			statusCode: http.StatusBadGateway,
+			want:       ErrServerError,
+			retryable:  true,
+		},
+		{
+			name:         "throttled reported in response text",
+			statusCode:   http.StatusOK,
+			responseText: "Webhook message delivery failed with error: Microsoft Teams endpoint returned HTTP error 429 with ContextId tcid=0,server=msgapi-production-eus-azsc2-4-170,cv=deadbeef.0..",
+			want:         ErrRateLimited,
+			retryable:    true,
+		},
+		{
+			name:         "payload too large reported in response text",
+			statusCode:   http.StatusOK,
+			responseText: "Webhook message delivery failed with error: Microsoft Teams endpoint returned HTTP error 413 with ContextId MS-CV=deadbeef.0",
+			want:         ErrPayloadTooLarge,
+		},
+		{
+			name:         "connector removed reported in response text",
+			statusCode:   http.StatusBadRequest,
+			responseText: "Connector configuration not found",
+			want:         ErrConnectorRemoved,
+		},
+	}
+
+	sentinels := []error{
+		ErrRateLimited,
+		ErrPayloadTooLarge,
+		ErrWebhookNotFound,
+		ErrConnectorRemoved,
+		ErrBadRequest,
+		ErrServerError,
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			sendErr := &SendError{
+				StatusCode:   tt.statusCode,
+				Status:       http.StatusText(tt.statusCode),
+				ResponseText: tt.responseText,
+			}
+			if tt.statusCode == http.StatusOK {
+				sendErr.Err = ErrInvalidWebhookURLResponseText
+			}
+
+			err := error(sendErr)
+
+			assert.Equal(t, tt.want, sendErr.Classification())
+			assert.Equal(t, tt.retryable, IsRetryableError(err))
+
+			for _, sentinel := range sentinels {
+				want := sentinel == tt.want ||
+					(sentinel == ErrWebhookNotFound && tt.want == ErrConnectorRemoved)
+				assert.Equal(t, want, errors.Is(err, sentinel), "errors.Is(err, %v)", sentinel)
+			}
+		})
+	}
+}
+
+func TestSendErrorDetails(t *testing.T) {
+	webhookURL := "https://example.webhook.office.com/webhookb2/a@b/IncomingWebhook/c/d"
+	throttled := scriptedResponse{
+		status: http.StatusTooManyRequests,
+		body:   "Too many requests",
+		header: http.Header{"Retry-After": []string{"2"}},
+	}
+
+	var requests int
+	clock := &fakeClock{now: time.Now()}
+	client := NewTeamsClient().
+		SetRetryPolicy(NewConstantBackoff(2, time.Second)).
+		SetClock(clock).
+		SetHTTPClient(newScriptedTestClient(&requests, throttled))
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	err := client.SendWithContext(context.Background(), webhookURL, &msgCard)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+
+	var sendErr *SendError
+	if assert.True(t, errors.As(err, &sendErr)) {
+		assert.Equal(t, http.StatusTooManyRequests, sendErr.StatusCode)
+		assert.Equal(t, "Too many requests", sendErr.ResponseText)
+		assert.Equal(t, "example.webhook.office.com", sendErr.Host)
+		assert.Equal(t, 3, sendErr.Attempt)
+		assert.Equal(t, 4*time.Second, sendErr.Elapsed)
+		assert.Equal(t, 2*time.Second, sendErr.RetryAfter)
+		assert.NotContains(t, err.Error(), "IncomingWebhook")
+	}
+
+	// Unexpected response text provided with a 200 status code remains
+	// identifiable as before.
+	requests = 0
+	client.SetRetryPolicy(nil).
+		SetHTTPClient(newScriptedTestClient(&requests, scriptedResponse{
+			status: http.StatusOK,
+			body:   "Webhook message delivery failed with error: Microsoft Teams endpoint returned HTTP error 500",
+		}))
+
+	err = client.SendWithContext(context.Background(), webhookURL, &msgCard)
+	assert.True(t, errors.Is(err, ErrInvalidWebhookURLResponseText))
+	assert.True(t, errors.Is(err, ErrServerError))
+	assert.True(t, errors.As(err, &sendErr))
+	assert.Equal(t, 1, sendErr.Attempt)
+}
@@ -0,0 +1,265 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+)
+
+// DefaultFanOutConcurrency is the default number of webhook URLs that a
+// message is submitted to concurrently by TeamsClient.SendToMany.
+const DefaultFanOutConcurrency int = 4
+
+// FanOutStatus is the outcome of submitting a message to a single webhook
+// URL as part of a TeamsClient.SendToMany call.
+type FanOutStatus int
+
+const (
+	// FanOutSucceeded indicates that the message was submitted successfully.
+	FanOutSucceeded FanOutStatus = iota
+
+	// FanOutFailed indicates that the message submission failed.
+	FanOutFailed
+
+	// FanOutSkipped indicates that the message was not submitted because
+	// the webhook URL failed validation.
+	FanOutSkipped
+)
+
+// String returns the name of the fan-out status.
+func (s FanOutStatus) String() string {
+	switch s {
+	case FanOutSucceeded:
+		return "succeeded"
+	case FanOutFailed:
+		return "failed"
+	case FanOutSkipped:
+		return "skipped"
+	default:
+		return fmt.Sprintf("FanOutStatus(%d)", int(s))
+	}
+}
+
+// FanOutResult is the result of submitting a message to a single webhook
+// URL.
+type FanOutResult struct {
+	// WebhookURL is the webhook URL that the message was submitted to.
+	WebhookURL string
+
+	// Status is the outcome of the message submission.
+	Status FanOutStatus
+
+	// Err is the error for a failed or skipped message submission.
+	Err error
+}
+
+// FanOutResults are the results of a TeamsClient.SendToMany call keyed by
+// webhook URL.
+type FanOutResults map[string]FanOutResult
+
+// Succeeded returns the sorted webhook URLs that the message was submitted
+// to successfully.
+func (r FanOutResults) Succeeded() []string {
+	return r.withStatus(FanOutSucceeded)
+}
+
+// Failed returns the sorted webhook URLs for which message submission
+// failed.
+func (r FanOutResults) Failed() []string {
+	return r.withStatus(FanOutFailed)
+}
+
+// Skipped returns the sorted webhook URLs which were skipped due to failed
+// validation.
+func (r FanOutResults) Skipped() []string {
+	return r.withStatus(FanOutSkipped)
+}
+
+// withStatus returns the sorted webhook URLs with the given status.
+func (r FanOutResults) withStatus(status FanOutStatus) []string {
+	var webhookURLs []string
+	for webhookURL, result := range r {
+		if result.Status == status {
+			webhookURLs = append(webhookURLs, webhookURL)
+		}
+	}
+	sort.Strings(webhookURLs)
+
+	return webhookURLs
+}
+
+// FanOutError is returned by TeamsClient.SendToMany when the message was not
+// submitted to one or more webhook URLs.
+//
+// errors.Is reports whether the error for any webhook URL matches the
+// target (e.g., ErrRateLimited).
+type FanOutError struct {
+	// Results are the results for all webhook URLs, including those that
+	// succeeded.
+	Results FanOutResults
+}
+
+// Error provides a summary of the failed and skipped webhook URLs.
+func (e *FanOutError) Error() string {
+	failed := len(e.Results.Failed())
+	skipped := len(e.Results.Skipped())
+
+	return fmt.Sprintf(
+		"failed to submit message to %d of %d webhook URLs (%d failed, %d skipped)",
+		failed+skipped,
+		len(e.Results),
+		failed,
+		skipped,
+	)
+}
+
+// Is indicates whether the error for any webhook URL matches the target.
+func (e *FanOutError) Is(target error) bool {
+	for _, result := range e.Results {
+		if result.Err != nil && errors.Is(result.Err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetFanOutConcurrency accepts the number of webhook URLs that a message is
+// submitted to concurrently by SendToMany. A value less than 1 applies
+// DefaultFanOutConcurrency.
+//
+// Deprecated: use the WithFanOutConcurrency option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetFanOutConcurrency(concurrency int) *TeamsClient {
+	c.fanOutConcurrency = concurrency
+
+	return c
+}
+
+// SendToMany submits a given message to each of the given webhook URLs
+// concurrently, up to the configured fan-out concurrency. The message is
+// validated and prepared once and the same prepared payload is submitted to
+// each webhook URL. Any RetryPolicy set for the client is applied to each
+// webhook URL independently.
+//
+// Webhook URLs which fail validation are skipped. Results are returned for
+// every webhook URL along with a *FanOutError if the message was not
+// submitted to all of them. An error is returned without results if the
+// message could not be prepared.
+func (c *TeamsClient) SendToMany(ctx context.Context, webhookURLs []string, message Message) (FanOutResults, error) {
+	if err := message.Validate(); err != nil {
+		return nil, &permanentError{fmt.Errorf(
+			"failed to validate message: %w",
+			err,
+		)}
+	}
+
+	snapshot, err := snapshotMessage(message)
+	if err != nil {
+		return nil, &permanentError{fmt.Errorf(
+			"failed to prepare message: %w",
+			err,
+		)}
+	}
+
+	payload, err := preparedPayload(snapshot)
+	if err != nil {
+		return nil, &permanentError{fmt.Errorf(
+			"failed to retrieve prepared message: %w",
+			err,
+		)}
+	}
+
+	// Oversized message cards are passed through as-is so that they may be
+	// split if requested.
+	prepared := Message(&RawMessage{payload: payload})
+	if card, ok := message.(*messagecard.MessageCard); ok && c.splitOversizedMessages {
+		prepared = card
+	}
+
+	concurrency := c.fanOutConcurrency
+	if concurrency < 1 {
+		concurrency = DefaultFanOutConcurrency
+	}
+
+	results := make(FanOutResults, len(webhookURLs))
+	var pending []string
+
+	for _, webhookURL := range webhookURLs {
+		if _, ok := results[webhookURL]; ok {
+			continue
+		}
+
+		if err := c.ValidateWebhook(webhookURL); err != nil {
+			results[webhookURL] = FanOutResult{
+				WebhookURL: webhookURL,
+				Status:     FanOutSkipped,
+				Err: fmt.Errorf(
+					"failed to validate webhook URL: %w",
+					err,
+				),
+			}
+
+			continue
+		}
+
+		results[webhookURL] = FanOutResult{WebhookURL: webhookURL}
+		pending = append(pending, webhookURL)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, webhookURL := range pending {
+		wg.Add(1)
+		go func(webhookURL string) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := FanOutResult{
+				WebhookURL: webhookURL,
+				Status:     FanOutSucceeded,
+			}
+
+			if err := c.SendWithContext(ctx, webhookURL, prepared); err != nil {
+				result.Status = FanOutFailed
+				result.Err = err
+			}
+
+			mu.Lock()
+			results[webhookURL] = result
+			mu.Unlock()
+		}(webhookURL)
+	}
+
+	wg.Wait()
+
+	c.Logger().Info(
+		"sent message to many webhook URLs",
+		"succeeded", len(results.Succeeded()),
+		"failed", len(results.Failed()),
+		"skipped", len(results.Skipped()),
+	)
+
+	for _, result := range results {
+		if result.Status != FanOutSucceeded {
+			return results, &FanOutError{Results: results}
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendToMany(t *testing.T) {
+	const (
+		onCall     = "https://outlook.office.com/webhook/on-call"
+		team       = "https://outlook.office.com/webhook/team"
+		management = "https://outlook.office.com/webhook/management"
+		invalid    = "https://example.com/webhook/invalid"
+	)
+
+	var mu sync.Mutex
+	var active, maxActive int
+	bodies := make(map[string]string)
+
+	httpClient := NewTestClient(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		active--
+		bodies[req.URL.Path] = string(body)
+		mu.Unlock()
+
+		status, text := http.StatusOK, ExpectedWebhookURLResponseText
+		if strings.HasSuffix(req.URL.Path, "management") {
+			status, text = http.StatusTooManyRequests, "Too many requests"
+		}
+
+		return &http.Response{
+			StatusCode: status,
+			Status:     http.StatusText(status),
+			Body:       ioutil.NopCloser(bytes.NewBufferString(text)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	client := NewTeamsClient().
+		SetHTTPClient(httpClient).
+		SetFanOutConcurrency(2)
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Database unavailable"
+
+	results, err := client.SendToMany(
+		context.Background(),
+		[]string{onCall, team, management, invalid, team},
+		&msgCard,
+	)
+
+	var fanOutErr *FanOutError
+	if assert.True(t, errors.As(err, &fanOutErr)) {
+		assert.Equal(t, "failed to submit message to 2 of 4 webhook URLs (1 failed, 1 skipped)", err.Error())
+	}
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	assert.True(t, errors.Is(err, ErrWebhookURLUnexpected))
+
+	assert.Len(t, results, 4)
+	assert.Equal(t, []string{onCall, team}, results.Succeeded())
+	assert.Equal(t, []string{management}, results.Failed())
+	assert.Equal(t, []string{invalid}, results.Skipped())
+	assert.True(t, errors.Is(results[management].Err, ErrRateLimited))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.LessOrEqual(t, maxActive, 2)
+	if assert.Len(t, bodies, 3) {
+		for _, body := range bodies {
+			assert.JSONEq(t, `{"@type":"MessageCard","@context":"https://schema.org/extensions","text":"Database unavailable"}`, body)
+		}
+	}
+}
+
+func TestSendToManyInvalidMessage(t *testing.T) {
+	msgCard := NewMessageCard()
+
+	results, err := NewTeamsClient().SendToMany(
+		context.Background(),
+		[]string{"https://outlook.office.com/webhook/xxx"},
+		&msgCard,
+	)
+
+	assert.Error(t, err)
+	assert.False(t, IsRetryableError(err))
+	assert.Nil(t, results)
+}
@@ -71,12 +71,11 @@ const (
 func TryToFormatAsCodeBlock(input string) string {
 	result, err := FormatAsCodeBlock(input)
 	if err != nil {
-		logger.Printf("TryToFormatAsCodeBlock: error occurred when calling FormatAsCodeBlock: %v\n", err)
-		logger.Println("TryToFormatAsCodeBlock: returning original string")
+		packageLogger.Debug("failed to format as code block; returning original string", "error", err)
 		return input
 	}
 
-	logger.Println("TryToFormatAsCodeBlock: no errors occurred when calling FormatAsCodeBlock")
+	packageLogger.Debug("formatted as code block")
 	return result
 }
 
@@ -87,12 +86,11 @@ func TryToFormatAsCodeBlock(input string) string {
 func TryToFormatAsCodeSnippet(input string) string {
 	result, err := FormatAsCodeSnippet(input)
 	if err != nil {
-		logger.Printf("TryToFormatAsCodeSnippet: error occurred when calling FormatAsCodeBlock: %v\n", err)
-		logger.Println("TryToFormatAsCodeSnippet: returning original string")
+		packageLogger.Debug("failed to format as code snippet; returning original string", "error", err)
 		return input
 	}
 
-	logger.Println("TryToFormatAsCodeSnippet: no errors occurred when calling FormatAsCodeSnippet")
+	packageLogger.Debug("formatted as code snippet")
 	return result
 }
 
@@ -145,8 +143,7 @@ func formatAsCode(input string, prefix string, suffix string) (string, error) {
 	// If the input string is already valid JSON, don't double-encode and
 	// escape the content
 	case json.Valid([]byte(input)):
-		logger.Printf("formatAsCode: input string already valid JSON; input: %+v", input)
-		logger.Printf("formatAsCode: Calling json.RawMessage([]byte(input)); input: %+v", input)
+		packageLogger.Debug("formatting input as code; input already valid JSON", "bytes", len(input))
 
 		// FIXME: Is json.RawMessage() really needed if the input string is
 		// *already* JSON? https://golang.org/pkg/encoding/json/#RawMessage
@@ -155,37 +152,30 @@ func formatAsCode(input string, prefix string, suffix string) (string, error) {
 		//
 		// From light testing, it appears to not be necessary:
 		//
-		// logger.Printf("formatAsCode: Skipping json.RawMessage, converting string directly to byte slice; input: %+v", input)
+		// packageLogger.Debug("skipping json.RawMessage, converting string directly to byte slice")
 		// byteSlice = []byte(input)
 
 	default:
-		logger.Printf("formatAsCode: input string not valid JSON; input: %+v", input)
-		logger.Printf("formatAsCode: Calling json.Marshal(input); input: %+v", input)
+		packageLogger.Debug("formatting input as code; encoding input as JSON", "bytes", len(input))
 		byteSlice, err = json.Marshal(input)
 		if err != nil {
 			return "", err
 		}
 	}
 
-	logger.Println("formatAsCode: byteSlice as string:", string(byteSlice))
-
 	var prettyJSON bytes.Buffer
 
-	logger.Println("formatAsCode: calling json.Indent")
 	err = json.Indent(&prettyJSON, byteSlice, "", "\t")
 	if err != nil {
 		return "", err
 	}
 	formattedJSON := prettyJSON.String()
 
-	logger.Println("formatAsCode: Formatted JSON:", formattedJSON)
-
 	// handle both cases: where the formatted JSON string was not wrapped with
 	// double-quotes and when it was
 	codeContentForSubmission := prefix + strings.Trim(formattedJSON, "\"") + suffix
 
-	logger.Printf("formatAsCode: formatted JSON as-is:\n%s\n\n", formattedJSON)
-	logger.Printf("formatAsCode: formatted JSON wrapped with code prefix/suffix: \n%s\n\n", codeContentForSubmission)
+	packageLogger.Debug("formatted input as code", "bytes", len(codeContentForSubmission))
 
 	// err should be nil if everything worked as expected
 	return codeContentForSubmission, err
@@ -194,8 +184,6 @@ func formatAsCode(input string, prefix string, suffix string) (string, error) {
 // ConvertEOLToBreak converts \r\n (windows), \r (mac) and \n (unix) into <br>
 // HTML/Markdown break statements.
 func ConvertEOLToBreak(s string) string {
-	logger.Printf("ConvertEOLToBreak: Received %#v", s)
-
 	s = strings.ReplaceAll(s, windowsEOLActual, breakStatement)
 	s = strings.ReplaceAll(s, windowsEOLEscaped, breakStatement)
 	s = strings.ReplaceAll(s, macEOLActual, breakStatement)
@@ -203,7 +191,5 @@ func ConvertEOLToBreak(s string) string {
 	s = strings.ReplaceAll(s, unixEOLActual, breakStatement)
 	s = strings.ReplaceAll(s, unixEOLEscaped, breakStatement)
 
-	logger.Printf("ConvertEOLToBreak: Returning %#v", s)
-
 	return s
 }
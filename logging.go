@@ -0,0 +1,234 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger is a leveled logger accepting a message along with alternating keys
+// and values providing context (e.g., "attempt", 2). A Logger may be set for
+// each TeamsClient; see WithLogger.
+type Logger interface {
+	// Debug logs diagnostic details.
+	Debug(msg string, keysAndValues ...interface{})
+
+	// Info logs routine events.
+	Info(msg string, keysAndValues ...interface{})
+
+	// Warn logs unexpected events which do not prevent message delivery
+	// (e.g., a failed attempt which will be retried).
+	Warn(msg string, keysAndValues ...interface{})
+
+	// Error logs failures.
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// LogLevel is the severity of a log entry.
+type LogLevel int
+
+const (
+	// LogLevelDebug is the severity of diagnostic log entries.
+	LogLevelDebug LogLevel = iota
+
+	// LogLevelInfo is the severity of routine log entries.
+	LogLevelInfo
+
+	// LogLevelWarn is the severity of log entries for unexpected events.
+	LogLevelWarn
+
+	// LogLevelError is the severity of log entries for failures.
+	LogLevelError
+)
+
+// String returns the name of the log level.
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	case LogLevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+}
+
+// redactedPath replaces the path of a webhook URL in log entries.
+const redactedPath string = "/REDACTED"
+
+// packageLogger is the Logger used by package-level functions and by clients
+// which have not been given a Logger. It writes to the package logger which
+// is muted unless EnableLogging is called.
+var packageLogger Logger
+
+// stdLogger is a Logger which writes entries to a *log.Logger.
+type stdLogger struct {
+	logger *log.Logger
+	level  LogLevel
+}
+
+// NewStdLogger creates a Logger which writes entries at or above the given
+// level to the given *log.Logger in logfmt style (e.g., level=info
+// msg="message sent" attempt=1).
+func NewStdLogger(logger *log.Logger, level LogLevel) Logger {
+	return &stdLogger{
+		logger: logger,
+		level:  level,
+	}
+}
+
+// Debug logs diagnostic details.
+func (l *stdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log(LogLevelDebug, msg, keysAndValues)
+}
+
+// Info logs routine events.
+func (l *stdLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(LogLevelInfo, msg, keysAndValues)
+}
+
+// Warn logs unexpected events.
+func (l *stdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log(LogLevelWarn, msg, keysAndValues)
+}
+
+// Error logs failures.
+func (l *stdLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.log(LogLevelError, msg, keysAndValues)
+}
+
+// log formats and writes an entry if the level is enabled.
+func (l *stdLogger) log(level LogLevel, msg string, keysAndValues []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("level=")
+	b.WriteString(level.String())
+	b.WriteString(" msg=")
+	b.WriteString(formatLogValue(msg))
+
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "(MISSING)"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+
+		b.WriteByte(' ')
+		b.WriteString(fmt.Sprint(keysAndValues[i]))
+		b.WriteByte('=')
+		b.WriteString(formatLogValue(value))
+	}
+
+	// Report the caller of the Logger method.
+	_ = l.logger.Output(3, b.String())
+}
+
+// formatLogValue formats a value for a log entry, quoting it if needed.
+func formatLogValue(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return fmt.Sprintf("%q", s)
+	}
+
+	return s
+}
+
+// nopLogger is a Logger which discards all entries.
+type nopLogger struct{}
+
+// NewNopLogger creates a Logger which discards all entries.
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+// Debug discards the entry.
+func (nopLogger) Debug(string, ...interface{}) {}
+
+// Info discards the entry.
+func (nopLogger) Info(string, ...interface{}) {}
+
+// Warn discards the entry.
+func (nopLogger) Warn(string, ...interface{}) {}
+
+// Error discards the entry.
+func (nopLogger) Error(string, ...interface{}) {}
+
+// SetLogger accepts a Logger which replaces the package logger for log
+// entries related to this client.
+//
+// Deprecated: use the WithLogger option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetLogger(logger Logger) *TeamsClient {
+	c.logger = logger
+
+	return c
+}
+
+// SetLogWebhookURLs allows the caller to optionally include full webhook
+// URLs in log entries. Webhook URLs contain secrets and are redacted by
+// default.
+//
+// Deprecated: use the WithLogWebhookURLs option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetLogWebhookURLs(include bool) *TeamsClient {
+	c.logWebhookURLs = include
+
+	return c
+}
+
+// Logger returns the Logger for the client.
+//
+// Deprecated: use TeamsClient.Logger() method instead.
+func (c *teamsClient) Logger() Logger {
+	return packageLogger
+}
+
+// Logger returns the configured Logger for the client. If a custom Logger is
+// not set the package logger is returned; see EnableLogging.
+func (c *TeamsClient) Logger() Logger {
+	if c.logger == nil {
+		return packageLogger
+	}
+
+	return c.logger
+}
+
+// loggedWebhookURL returns the given webhook URL as it should appear in log
+// entries.
+func (c *teamsClient) loggedWebhookURL(webhookURL string) string {
+	return redactWebhookURL(webhookURL)
+}
+
+// loggedWebhookURL returns the given webhook URL as it should appear in log
+// entries.
+func (c *TeamsClient) loggedWebhookURL(webhookURL string) string {
+	if c.logWebhookURLs {
+		return webhookURL
+	}
+
+	return redactWebhookURL(webhookURL)
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingLogger is a Logger which records formatted entries.
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) record(level LogLevel, msg string, keysAndValues []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, fmt.Sprint(level, " ", msg, " ", keysAndValues))
+}
+
+func (l *recordingLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.record(LogLevelDebug, msg, keysAndValues)
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.record(LogLevelInfo, msg, keysAndValues)
+}
+
+func (l *recordingLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.record(LogLevelWarn, msg, keysAndValues)
+}
+
+func (l *recordingLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.record(LogLevelError, msg, keysAndValues)
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0), LogLevelInfo)
+
+	logger.Debug("not written")
+	logger.Info("message sent", "attempts", 2, "webhook", "https://outlook.office.com/REDACTED")
+	logger.Error("failed", "error", errors.New("connection reset by peer"), "dangling")
+
+	assert.Equal(t,
+		"level=info msg=\"message sent\" attempts=2 webhook=https://outlook.office.com/REDACTED\n"+
+			"level=error msg=failed error=\"connection reset by peer\" dangling=(MISSING)\n",
+		buf.String(),
+	)
+}
+
+func TestTeamsClientLoggerRedactsWebhookURLs(t *testing.T) {
+	webhookURL := "https://example.webhook.office.com/webhookb2/secret-group@secret-tenant/IncomingWebhook/secret-id/secret-owner"
+
+	var requests int
+	logger := &recordingLogger{}
+	client := NewTeamsClient().
+		SetLogger(logger).
+		SetRetryPolicy(NewConstantBackoff(1, 0)).
+		SetHTTPClient(newScriptedTestClient(&requests,
+			scriptedResponse{status: http.StatusInternalServerError},
+			scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText},
+		))
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	assert.NoError(t, client.SendWithContext(context.Background(), webhookURL, &msgCard))
+
+	logged := strings.Join(logger.entries, "\n")
+	assert.Contains(t, logged, "attempt to send message failed")
+	assert.Contains(t, logged, "https://example.webhook.office.com/REDACTED")
+	assert.NotContains(t, logged, "secret")
+	assert.NotContains(t, logged, "Hello World")
+
+	logger.entries = nil
+	client.SetLogWebhookURLs(true)
+
+	assert.NoError(t, client.SendWithContext(context.Background(), webhookURL, &msgCard))
+	assert.Contains(t, strings.Join(logger.entries, "\n"), webhookURL)
+
+	// Clients without a custom Logger are unaffected.
+	assert.Equal(t, packageLogger, NewTeamsClient().Logger())
+	NewTeamsClient().SetLogger(NewNopLogger()).Logger().Error("discarded")
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrInvalidRawMessage indicates that the payload of a RawMessage is not
+// valid JSON.
+var ErrInvalidRawMessage = errors.New("raw message payload is not valid JSON")
+
+// Message is the interface shared by all supported message formats for
+// submission to a Microsoft Teams channel. The messagecard.MessageCard and
+// botapi.Message types implement Message; custom payload types may also be
+// submitted by implementing it.
+type Message interface {
+	// Validate performs validation of the message format, returning an error
+	// if the message cannot be submitted.
+	Validate() error
+
+	// Prepare marshals the message as preparation for delivery to an
+	// endpoint. An existing prepared payload is replaced if recreate is
+	// true.
+	Prepare(recreate bool) error
+
+	// Payload returns a new reader for the prepared payload so that the
+	// prepared payload may be read as many times as needed (e.g., when a
+	// message is submitted again after a failed attempt).
+	Payload() io.Reader
+}
+
+// Sender describes a client which submits messages to a Microsoft Teams
+// channel. TeamsClient and AsyncClient satisfy Sender, allowing client code
+// to substitute a fake Sender in tests.
+type Sender interface {
+	// Send submits a given message using the provided webhook URL.
+	Send(webhookURL string, message Message) error
+
+	// SendWithContext submits a given message using the provided webhook URL,
+	// honoring the cancellation or timeout of the provided context.
+	SendWithContext(ctx context.Context, webhookURL string, message Message) error
+}
+
+// Compile-time checks that clients satisfy Sender.
+var (
+	_ Sender = (*TeamsClient)(nil)
+	_ Sender = (*AsyncClient)(nil)
+)
+
+// RawMessage is a message consisting of a pre-built JSON payload which is
+// submitted unchanged.
+type RawMessage struct {
+	// ValidateFunc is an optional validation function applied to the payload
+	// by Validate after confirming that it is valid JSON.
+	ValidateFunc func(payload []byte) error
+
+	payload []byte
+}
+
+// NewRawMessage creates a RawMessage using a copy of the given JSON payload.
+func NewRawMessage(payload []byte) *RawMessage {
+	return &RawMessage{
+		payload: append([]byte(nil), payload...),
+	}
+}
+
+// Validate confirms that the payload is valid JSON, then applies
+// ValidateFunc if set.
+func (m *RawMessage) Validate() error {
+	if len(m.payload) == 0 {
+		return fmt.Errorf("%w: payload is empty", ErrInvalidRawMessage)
+	}
+
+	if !json.Valid(m.payload) {
+		return ErrInvalidRawMessage
+	}
+
+	if m.ValidateFunc != nil {
+		return m.ValidateFunc(m.payload)
+	}
+
+	return nil
+}
+
+// Prepare is a no-op; the payload is already prepared.
+func (m *RawMessage) Prepare(recreate bool) error {
+	return nil
+}
+
+// Payload returns the payload.
+func (m *RawMessage) Payload() io.Reader {
+	return bytes.NewReader(m.payload)
+}
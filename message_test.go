@@ -0,0 +1,125 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawMessage(t *testing.T) {
+	payload := []byte(`{"text":"Hello World"}`)
+	msg := NewRawMessage(payload)
+
+	// The payload is copied.
+	payload[2] = 'X'
+
+	assert.NoError(t, msg.Validate())
+	assert.NoError(t, msg.Prepare(true))
+
+	got, err := ioutil.ReadAll(msg.Payload())
+	requireNoError(t, err)
+	assert.Equal(t, `{"text":"Hello World"}`, string(got))
+
+	assert.True(t, errors.Is(NewRawMessage(nil).Validate(), ErrInvalidRawMessage))
+	assert.True(t, errors.Is(NewRawMessage([]byte(`{"text":`)).Validate(), ErrInvalidRawMessage))
+
+	errNoTitle := errors.New("title is required")
+	msg.ValidateFunc = func(payload []byte) error {
+		if !bytes.Contains(payload, []byte(`"title"`)) {
+			return errNoTitle
+		}
+
+		return nil
+	}
+	assert.Equal(t, errNoTitle, msg.Validate())
+
+	var requests int
+	client := NewTeamsClient().SetHTTPClient(newScriptedTestClient(&requests,
+		scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText},
+	))
+
+	err = client.Send("https://outlook.office.com/webhook/xxx", msg)
+	assert.True(t, errors.Is(err, errNoTitle))
+	assert.Equal(t, 0, requests)
+}
+
+// upperMessage is a custom Message type.
+type upperMessage struct {
+	text    string
+	payload string
+}
+
+func (m *upperMessage) Validate() error {
+	if m.text == "" {
+		return errors.New("text is required")
+	}
+
+	return nil
+}
+
+func (m *upperMessage) Prepare(recreate bool) error {
+	m.payload = `{"text":"` + strings.ToUpper(m.text) + `"}`
+
+	return nil
+}
+
+func (m *upperMessage) Payload() io.Reader {
+	return strings.NewReader(m.payload)
+}
+
+// recordingSender is a fake Sender.
+type recordingSender struct {
+	sent []Message
+}
+
+func (s *recordingSender) Send(webhookURL string, message Message) error {
+	return s.SendWithContext(context.Background(), webhookURL, message)
+}
+
+func (s *recordingSender) SendWithContext(ctx context.Context, webhookURL string, message Message) error {
+	s.sent = append(s.sent, message)
+
+	return nil
+}
+
+func TestCustomMessageAndSender(t *testing.T) {
+	var received string
+	client := NewTeamsClient().SetHTTPClient(NewTestClient(func(req *http.Request) (*http.Response, error) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		received = string(body)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(ExpectedWebhookURLResponseText)),
+			Header:     make(http.Header),
+		}, nil
+	}))
+
+	notify := func(sender Sender, text string) error {
+		return sender.Send("https://outlook.office.com/webhook/xxx", &upperMessage{text: text})
+	}
+
+	assert.NoError(t, notify(client, "hello"))
+	assert.Equal(t, `{"text":"HELLO"}`, received)
+
+	fake := &recordingSender{}
+	assert.NoError(t, notify(fake, "hello"))
+	assert.Len(t, fake.sent, 1)
+}
@@ -472,8 +472,10 @@ type MessageCard struct {
 	PotentialActions []*MessageCardPotentialAction `json:"potentialAction,omitempty"`
 
 	// payload is a prepared MessageCard in JSON format for submission or
-	// pretty printing.
-	payload *bytes.Buffer `json:"-"`
+	// pretty printing. The prepared bytes are not modified once set so that
+	// the same payload can be replayed for retries, redirects or delivery to
+	// multiple endpoints.
+	payload []byte `json:"-"`
 }
 
 // validatePotentialAction inspects the given *MessageCardPotentialAction
@@ -504,16 +506,14 @@ func validatePotentialAction(pa *MessageCardPotentialAction) error {
 // PotentialActions collection.
 func addPotentialAction(collection *[]*MessageCardPotentialAction, actions ...*MessageCardPotentialAction) error {
 	for _, a := range actions {
-		logger.Printf("addPotentialAction: MessageCardPotentialAction received: %+v\n", a)
-
 		if err := validatePotentialAction(a); err != nil {
-			logger.Printf("addPotentialAction: validation failed: %v", err)
+			packageLogger.Debug("potential action validation failed", "error", err)
 
 			return err
 		}
 
 		if len(*collection) > PotentialActionMaxSupported {
-			logger.Printf("addPotentialAction: failed to add potential action: %v", ErrPotentialActionsLimitReached.Error())
+			packageLogger.Debug("failed to add potential action", "error", ErrPotentialActionsLimitReached)
 
 			return fmt.Errorf("func addPotentialAction: failed to add potential action: %w", ErrPotentialActionsLimitReached)
 		}
@@ -531,8 +531,6 @@ func addPotentialAction(collection *[]*MessageCardPotentialAction, actions ...*M
 // Deprecated: use (messagecard.MessageCard).AddSection instead.
 func (mc *MessageCard) AddSection(section ...*MessageCardSection) error {
 	for _, s := range section {
-		logger.Printf("AddSection: MessageCardSection received: %+v\n", s)
-
 		// bail if a completely nil section provided
 		if s == nil {
 			return fmt.Errorf("func AddSection: nil MessageCardSection received")
@@ -562,12 +560,12 @@ func (mc *MessageCard) AddSection(section ...*MessageCardSection) error {
 		case s.Title != "":
 
 		default:
-			logger.Println("AddSection: No cases matched, all fields assumed to be at zero-value, skipping section")
+			packageLogger.Debug("all section fields at zero-value, skipping section")
 			return fmt.Errorf("all fields found to be at zero-value, skipping section")
 		}
 
-		logger.Println("AddSection: section contains at least one non-zero value, adding section")
 		mc.Sections = append(mc.Sections, s)
+		mc.payload = nil
 	}
 
 	return nil
@@ -578,6 +576,8 @@ func (mc *MessageCard) AddSection(section ...*MessageCardSection) error {
 //
 // Deprecated: use (messagecard.MessageCard).AddPotentialAction instead.
 func (mc *MessageCard) AddPotentialAction(actions ...*MessageCardPotentialAction) error {
+	mc.payload = nil
+
 	return addPotentialAction(&mc.PotentialActions, actions...)
 }
 
@@ -602,32 +602,48 @@ func (mc *MessageCard) Validate() error {
 }
 
 // Prepare handles tasks needed to prepare a MessageCard for delivery to an
-// endpoint. If specified, tasks are repeated regardless of whether a previous
-// Prepare call was made. Validation should be performed by the caller prior
-// to calling this method.
+// endpoint. The prepared payload is replaced if the MessageCard has been
+// modified since a previous Prepare call or if recreate is specified.
+// Validation should be performed by the caller prior to calling this method.
+//
+// Prepare modifies the MessageCard; the MessageCard is not modified when
+// submitted by a client so that it may be submitted from multiple
+// goroutines.
 //
 // Deprecated: use (messagecard.MessageCard).Prepare instead.
 func (mc *MessageCard) Prepare(recreate bool) error {
-	if mc.payload != nil && !recreate {
-		return nil
-	}
-
 	jsonMessage, err := json.Marshal(mc)
 	if err != nil {
 		return err
 	}
 
-	mc.payload = bytes.NewBuffer(jsonMessage)
+	if recreate || !bytes.Equal(jsonMessage, mc.payload) {
+		mc.payload = jsonMessage
+	}
 
 	return nil
 }
 
+// snapshot returns an immutable prepared copy of the MessageCard reflecting
+// its current content. The MessageCard is not modified.
+func (mc *MessageCard) snapshot() (*RawMessage, error) {
+	jsonMessage, err := json.Marshal(mc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RawMessage{payload: jsonMessage}, nil
+}
+
 // Payload returns the prepared MessageCard payload. The caller should call
 // Prepare() prior to calling this method, results are undefined otherwise.
 //
+// A new reader is returned for each call as required by the Message
+// interface.
+//
 // Deprecated: use (messagecard.MessageCard).Payload instead.
 func (mc *MessageCard) Payload() io.Reader {
-	return mc.payload
+	return bytes.NewReader(mc.payload)
 }
 
 // PrettyPrint returns a formatted JSON payload of the MessageCard if the
@@ -639,7 +655,7 @@ func (mc *MessageCard) PrettyPrint() string {
 		var prettyJSON bytes.Buffer
 
 		// Validation is handled by the MessageCard.Prepare() method.
-		_ = json.Indent(&prettyJSON, mc.payload.Bytes(), "", "\t")
+		_ = json.Indent(&prettyJSON, mc.payload, "", "\t")
 
 		return prettyJSON.String()
 	}
@@ -653,8 +669,6 @@ func (mc *MessageCard) PrettyPrint() string {
 // Deprecated: use (messagecard.Section).AddFact instead.
 func (mcs *MessageCardSection) AddFact(fact ...MessageCardSectionFact) error {
 	for _, f := range fact {
-		logger.Printf("AddFact: MessageCardSectionFact received: %+v\n", f)
-
 		if f.Name == "" {
 			return fmt.Errorf("empty Name field received for new fact: %+v", f)
 		}
@@ -664,7 +678,6 @@ func (mcs *MessageCardSection) AddFact(fact ...MessageCardSectionFact) error {
 		}
 	}
 
-	logger.Println("AddFact: section fact contains at least one non-zero value, adding section fact")
 	mcs.Facts = append(mcs.Facts, fact...)
 
 	return nil
@@ -0,0 +1,169 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package messagecard
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Snapshot is an immutable prepared MessageCard payload. A Snapshot is safe
+// for concurrent use and is not affected by later changes to the
+// MessageCard it was taken from.
+type Snapshot struct {
+	payload []byte
+}
+
+// Snapshot returns an immutable prepared payload reflecting the current
+// content of the MessageCard. The MessageCard is not modified; validation
+// should be performed by the caller prior to calling this method.
+func (mc *MessageCard) Snapshot() (*Snapshot, error) {
+	payload, err := json.Marshal(mc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{payload: payload}, nil
+}
+
+// Validate is a no-op; the MessageCard is validated before a Snapshot is
+// taken.
+func (s *Snapshot) Validate() error {
+	return nil
+}
+
+// Prepare is a no-op; the payload is already prepared.
+func (s *Snapshot) Prepare(recreate bool) error {
+	return nil
+}
+
+// Payload returns a new reader for the prepared payload.
+func (s *Snapshot) Payload() io.Reader {
+	return bytes.NewReader(s.payload)
+}
+
+// Bytes returns a copy of the prepared payload.
+func (s *Snapshot) Bytes() []byte {
+	return append([]byte(nil), s.payload...)
+}
+
+// Clone returns a deep copy of the MessageCard. The ValidateFunc field is
+// shared with the copy.
+func (mc *MessageCard) Clone() *MessageCard {
+	if mc == nil {
+		return nil
+	}
+
+	clone := *mc
+
+	if mc.Sections != nil {
+		clone.Sections = make([]*Section, len(mc.Sections))
+		for i, section := range mc.Sections {
+			clone.Sections[i] = section.Clone()
+		}
+	}
+
+	clone.PotentialActions = clonePotentialActions(mc.PotentialActions)
+
+	if mc.payload != nil {
+		clone.payload = append([]byte(nil), mc.payload...)
+	}
+
+	return &clone
+}
+
+// Clone returns a deep copy of the Section.
+func (mcs *Section) Clone() *Section {
+	if mcs == nil {
+		return nil
+	}
+
+	clone := *mcs
+
+	if mcs.HeroImage != nil {
+		heroImage := *mcs.HeroImage
+		clone.HeroImage = &heroImage
+	}
+
+	if mcs.Facts != nil {
+		clone.Facts = append([]SectionFact(nil), mcs.Facts...)
+	}
+
+	if mcs.Images != nil {
+		clone.Images = make([]*SectionImage, len(mcs.Images))
+		for i, image := range mcs.Images {
+			if image != nil {
+				img := *image
+				clone.Images[i] = &img
+			}
+		}
+	}
+
+	clone.PotentialActions = clonePotentialActions(mcs.PotentialActions)
+
+	return &clone
+}
+
+// Clone returns a deep copy of the PotentialAction. The
+// InitializationContext field is shared with the copy.
+func (pa *PotentialAction) Clone() *PotentialAction {
+	if pa == nil {
+		return nil
+	}
+
+	clone := *pa
+
+	if pa.Targets != nil {
+		clone.Targets = append([]PotentialActionOpenURITarget(nil), pa.Targets...)
+	}
+
+	if pa.Headers != nil {
+		clone.Headers = append([]PotentialActionHTTPPOSTHeader(nil), pa.Headers...)
+	}
+
+	if pa.Actions != nil {
+		clone.Actions = make([]PotentialActionActionCardAction, len(pa.Actions))
+		for i, action := range pa.Actions {
+			if action.Targets != nil {
+				action.Targets = append([]PotentialActionOpenURITarget(nil), action.Targets...)
+			}
+			if action.Headers != nil {
+				action.Headers = append([]PotentialActionHTTPPOSTHeader(nil), action.Headers...)
+			}
+			clone.Actions[i] = action
+		}
+	}
+
+	if pa.Inputs != nil {
+		clone.Inputs = make([]PotentialActionActionCardInput, len(pa.Inputs))
+		for i, input := range pa.Inputs {
+			if input.Choices != nil {
+				input.Choices = append(input.Choices[:0:0], input.Choices...)
+			}
+			clone.Inputs[i] = input
+		}
+	}
+
+	return &clone
+}
+
+// clonePotentialActions returns a deep copy of the given PotentialAction
+// collection.
+func clonePotentialActions(actions []*PotentialAction) []*PotentialAction {
+	if actions == nil {
+		return nil
+	}
+
+	clone := make([]*PotentialAction, len(actions))
+	for i, action := range actions {
+		clone[i] = action.Clone()
+	}
+
+	return clone
+}
@@ -408,8 +408,10 @@ type MessageCard struct {
 	PotentialActions []*PotentialAction `json:"potentialAction,omitempty"`
 
 	// payload is a prepared MessageCard in JSON format for submission or
-	// pretty printing.
-	payload *bytes.Buffer `json:"-"`
+	// pretty printing. The prepared bytes are not modified once set so that
+	// the same payload can be replayed for retries, redirects or delivery to
+	// multiple endpoints.
+	payload []byte `json:"-"`
 }
 
 // validatePotentialAction inspects the given *PotentialAction
@@ -491,6 +493,7 @@ func (mc *MessageCard) AddSection(section ...*Section) error {
 		}
 
 		mc.Sections = append(mc.Sections, s)
+		mc.payload = nil
 	}
 
 	return nil
@@ -499,6 +502,8 @@ func (mc *MessageCard) AddSection(section ...*Section) error {
 // AddPotentialAction adds one or many PotentialAction values to a
 // PotentialActions collection on a MessageCard.
 func (mc *MessageCard) AddPotentialAction(actions ...*PotentialAction) error {
+	mc.payload = nil
+
 	return addPotentialAction(&mc.PotentialActions, actions...)
 }
 
@@ -521,28 +526,32 @@ func (mc *MessageCard) Validate() error {
 }
 
 // Prepare handles tasks needed to prepare a MessageCard for delivery to an
-// endpoint. If specified, tasks are repeated regardless of whether a previous
-// Prepare call was made. Validation should be performed by the caller prior
-// to calling this method.
+// endpoint. The prepared payload is replaced if the MessageCard has been
+// modified since a previous Prepare call or if recreate is specified.
+// Validation should be performed by the caller prior to calling this method.
+//
+// Prepare modifies the MessageCard; use Snapshot or Clone to submit the
+// same MessageCard from multiple goroutines.
 func (mc *MessageCard) Prepare(recreate bool) error {
-	if mc.payload != nil && !recreate {
-		return nil
-	}
-
 	jsonMessage, err := json.Marshal(mc)
 	if err != nil {
 		return err
 	}
 
-	mc.payload = bytes.NewBuffer(jsonMessage)
+	if recreate || !bytes.Equal(jsonMessage, mc.payload) {
+		mc.payload = jsonMessage
+	}
 
 	return nil
 }
 
 // Payload returns the prepared MessageCard payload. The caller should call
 // Prepare() prior to calling this method, results are undefined otherwise.
+//
+// A new reader is returned for each call as required by the goteamsnotify
+// Message interface.
 func (mc *MessageCard) Payload() io.Reader {
-	return mc.payload
+	return bytes.NewReader(mc.payload)
 }
 
 // PrettyPrint returns a formatted JSON payload of the MessageCard if the
@@ -552,7 +561,7 @@ func (mc *MessageCard) PrettyPrint() string {
 		var prettyJSON bytes.Buffer
 
 		// Validation is handled by the MessageCard.Prepare() method.
-		_ = json.Indent(&prettyJSON, mc.payload.Bytes(), "", "\t")
+		_ = json.Indent(&prettyJSON, mc.payload, "", "\t")
 
 		return prettyJSON.String()
 	}
@@ -0,0 +1,445 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package messagecard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrSplitLimitExceeded indicates that a MessageCard could not be split into
+// cards within the requested size, usually because a single value (e.g., a
+// fact or image URL) exceeds the size on its own.
+var ErrSplitLimitExceeded = errors.New("message card content exceeds split size limit")
+
+// splitReserve is the number of bytes reserved in each card produced by
+// Split for the numbering added to the title and summary.
+const splitReserve int = 64
+
+// codeFence is the Markdown delimiter for a fenced code block.
+const codeFence string = "```"
+
+// Split divides the MessageCard into numbered cards (e.g., "Build log
+// (2/3)") whose JSON payloads do not exceed the given size in bytes. The
+// MessageCard is returned as-is if it is within the given size.
+//
+// Card text is split on line boundaries; any open code fence is closed at
+// the end of a card and reopened at the start of the next. Sections are
+// packed into cards in order, with the text and facts of sections too large
+// for a single card divided across continuation sections. Card-level
+// potential actions are included with the final card. A ValidateFunc set
+// on the MessageCard is not applied to the returned cards.
+func (mc *MessageCard) Split(maxSize int) ([]*MessageCard, error) {
+	size, err := cardSize(mc)
+	if err != nil {
+		return nil, err
+	}
+
+	if size <= maxSize {
+		return []*MessageCard{mc}, nil
+	}
+
+	limit := maxSize - splitReserve
+
+	newPart := func() *MessageCard {
+		return &MessageCard{
+			Type:       mc.Type,
+			Context:    mc.Context,
+			Summary:    mc.Summary,
+			Title:      mc.Title,
+			ThemeColor: mc.ThemeColor,
+		}
+	}
+
+	var parts []*MessageCard
+	current := newPart()
+
+	if mc.Text != "" {
+		probe := newPart()
+		probe.Text = "x"
+		budget, err := remaining(probe, limit, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		chunks, err := splitText(mc.Text, budget)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, chunk := range chunks {
+			if i > 0 {
+				parts = append(parts, current)
+				current = newPart()
+			}
+			current.Text = chunk
+		}
+	}
+
+	for _, section := range mc.Sections {
+		if section == nil {
+			continue
+		}
+
+		pieces, err := splitSection(section, newPart(), limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, piece := range pieces {
+			current.Sections = append(current.Sections, piece)
+
+			fits, err := withinSize(current, limit)
+			if err != nil {
+				return nil, err
+			}
+
+			if !fits && (len(current.Sections) > 1 || current.Text != "") {
+				current.Sections = current.Sections[:len(current.Sections)-1]
+				parts = append(parts, current)
+				current = newPart()
+				current.Sections = []*Section{piece}
+			}
+		}
+	}
+
+	if len(mc.PotentialActions) > 0 {
+		current.PotentialActions = mc.PotentialActions
+
+		fits, err := withinSize(current, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		if !fits {
+			current.PotentialActions = nil
+			parts = append(parts, current)
+			current = newPart()
+			current.PotentialActions = mc.PotentialActions
+		}
+	}
+
+	parts = append(parts, current)
+
+	for i, part := range parts {
+		label := fmt.Sprintf("(%d/%d)", i+1, len(parts))
+		part.Title = strings.TrimSpace(mc.Title + " " + label)
+		if part.Text == "" && part.Summary == "" {
+			part.Summary = label
+		}
+
+		fits, err := withinSize(part, maxSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if !fits {
+			return nil, fmt.Errorf(
+				"func Split: card %s exceeds %d bytes: %w",
+				label,
+				maxSize,
+				ErrSplitLimitExceeded,
+			)
+		}
+	}
+
+	return parts, nil
+}
+
+// splitSection divides the given Section into sections which each fit
+// within the given size when added to the given (empty) card. The first
+// returned section retains all Section fields other than the text and
+// facts, which are divided across continuation sections as needed.
+func splitSection(section *Section, base *MessageCard, limit int) ([]*Section, error) {
+	base.Sections = []*Section{section}
+
+	fits, err := withinSize(base, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if fits {
+		return []*Section{section}, nil
+	}
+
+	head := *section
+	head.Text = ""
+	head.Facts = nil
+
+	var pieces []*Section
+	if hasHeading(&head) {
+		base.Sections = []*Section{&head}
+
+		fits, err := withinSize(base, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		if !fits {
+			return nil, fmt.Errorf(
+				"func splitSection: section %q exceeds %d bytes: %w",
+				section.Title,
+				limit,
+				ErrSplitLimitExceeded,
+			)
+		}
+
+		pieces = append(pieces, &head)
+	}
+
+	if section.Text != "" {
+		base.Sections = []*Section{{Text: "x", Markdown: section.Markdown}}
+		budget, err := remaining(base, limit, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		chunks, err := splitText(section.Text, budget)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, chunk := range chunks {
+			pieces = append(pieces, &Section{Text: chunk, Markdown: section.Markdown})
+		}
+	}
+
+	var facts *Section
+	for _, fact := range section.Facts {
+		if facts != nil {
+			facts.Facts = append(facts.Facts, fact)
+			base.Sections = []*Section{facts}
+
+			fits, err := withinSize(base, limit)
+			if err != nil {
+				return nil, err
+			}
+
+			if fits {
+				continue
+			}
+
+			facts.Facts = facts.Facts[:len(facts.Facts)-1]
+		}
+
+		facts = &Section{Facts: []SectionFact{fact}, Markdown: section.Markdown}
+		base.Sections = []*Section{facts}
+
+		fits, err := withinSize(base, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		if !fits {
+			return nil, fmt.Errorf(
+				"func splitSection: fact %q exceeds %d bytes: %w",
+				fact.Name,
+				limit,
+				ErrSplitLimitExceeded,
+			)
+		}
+
+		pieces = append(pieces, facts)
+	}
+
+	if len(pieces) > 0 {
+		pieces[0].StartGroup = section.StartGroup
+	}
+
+	return pieces, nil
+}
+
+// hasHeading indicates whether the given Section has content other than
+// text and facts.
+func hasHeading(section *Section) bool {
+	return section.Title != "" ||
+		section.ActivityImage != "" ||
+		section.ActivityTitle != "" ||
+		section.ActivitySubtitle != "" ||
+		section.ActivityText != "" ||
+		section.HeroImage != nil ||
+		len(section.Images) > 0 ||
+		len(section.PotentialActions) > 0
+}
+
+// splitText divides the given text into chunks on line boundaries such that
+// the JSON encoded length of each chunk (excluding quotes) does not exceed
+// the given budget. A line longer than the budget is divided as needed. If a
+// code fence is open at the end of a chunk, it is closed and then reopened
+// at the start of the next chunk.
+func splitText(text string, budget int) ([]string, error) {
+	var chunks []string
+	var lines []string
+	var size int
+	var carried int
+	var fence string
+
+	newlineLen := escapedLen("\n")
+	closeLen := escapedLen("\n" + codeFence)
+
+	flush := func() {
+		chunk := strings.Join(lines, "\n")
+		if fence != "" {
+			chunk += "\n" + codeFence
+		}
+		chunks = append(chunks, chunk)
+
+		lines, size, carried = nil, 0, 0
+		if fence != "" {
+			lines, size, carried = []string{fence}, escapedLen(fence), 1
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		for {
+			nextFence := fence
+			if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, codeFence) {
+				if fence == "" {
+					nextFence = trimmed
+				} else {
+					nextFence = ""
+				}
+			}
+
+			separator := 0
+			if len(lines) > 0 {
+				separator = newlineLen
+			}
+
+			reserve := 0
+			if nextFence != "" {
+				reserve = closeLen
+			}
+
+			lineLen := escapedLen(line)
+			if size+separator+lineLen+reserve <= budget {
+				lines = append(lines, line)
+				size += separator + lineLen
+				fence = nextFence
+
+				break
+			}
+
+			if len(lines) > carried {
+				flush()
+				continue
+			}
+
+			// The line does not fit on its own; take as much as possible.
+			reserve = 0
+			if fence != "" {
+				reserve = closeLen
+			}
+
+			head := truncateEscaped(line, budget-size-separator-reserve)
+			if head == "" {
+				return nil, fmt.Errorf(
+					"func splitText: unable to fit text within %d bytes: %w",
+					budget,
+					ErrSplitLimitExceeded,
+				)
+			}
+
+			lines = append(lines, head)
+			flush()
+			line = line[len(head):]
+		}
+	}
+
+	if len(lines) > carried || len(chunks) == 0 {
+		chunks = append(chunks, strings.Join(lines, "\n"))
+	}
+
+	return chunks, nil
+}
+
+// truncateEscaped returns the longest prefix of the given string, ending on
+// a rune boundary, whose JSON encoded length does not exceed the given size.
+func truncateEscaped(s string, size int) string {
+	var used int
+	for i, r := range s {
+		n := escapedLen(string(r))
+		if used+n > size {
+			return s[:i]
+		}
+		used += n
+	}
+
+	return s
+}
+
+// escapedLen returns the length of the given string once JSON encoded,
+// excluding the surrounding quotes.
+func escapedLen(s string) int {
+	if !needsEscape(s) {
+		return len(s)
+	}
+
+	// Marshaling a string does not fail.
+	b, _ := json.Marshal(s)
+
+	return len(b) - 2
+}
+
+// needsEscape indicates whether the given string contains characters which
+// are escaped when JSON encoded.
+func needsEscape(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' || c >= utf8.RuneSelf {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remaining returns the number of bytes available within the given size for
+// a value in the given card, which has been populated with a placeholder
+// value of the given encoded length.
+func remaining(card *MessageCard, limit int, placeholder int) (int, error) {
+	size, err := cardSize(card)
+	if err != nil {
+		return 0, err
+	}
+
+	budget := limit - (size - placeholder)
+	if budget <= 0 {
+		return 0, fmt.Errorf(
+			"func remaining: no space available within %d bytes: %w",
+			limit,
+			ErrSplitLimitExceeded,
+		)
+	}
+
+	return budget, nil
+}
+
+// withinSize indicates whether the JSON payload of the given card is within
+// the given size.
+func withinSize(card *MessageCard, limit int) (bool, error) {
+	size, err := cardSize(card)
+	if err != nil {
+		return false, err
+	}
+
+	return size <= limit, nil
+}
+
+// cardSize returns the length of the JSON payload for the given card.
+func cardSize(card *MessageCard) (int, error) {
+	b, err := json.Marshal(card)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package messagecard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitText(t *testing.T) {
+	tests := map[string]struct {
+		text    string
+		budget  int
+		want    []string
+		wantErr bool
+	}{
+		"within budget": {
+			text:   "a\nb",
+			budget: 10,
+			want:   []string{"a\nb"},
+		},
+		"line boundaries": {
+			text:   "a\nb\nc",
+			budget: 4,
+			want:   []string{"a\nb", "c"},
+		},
+		"long line": {
+			text:   "abcdefg",
+			budget: 3,
+			want:   []string{"abc", "def", "g"},
+		},
+		"multi-byte runes": {
+			text:   "日本語",
+			budget: 7,
+			want:   []string{"日本", "語"},
+		},
+		"escaped characters": {
+			text:   "a<b",
+			budget: 6,
+			want:   []string{"a", "<", "b"},
+		},
+		"code fence": {
+			text:   "```\nx\ny\n```",
+			budget: 12,
+			want:   []string{"```\nx\n```", "```\ny\n```"},
+		},
+		"rune exceeds budget": {
+			text:    "<",
+			budget:  5,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		got, err := splitText(tt.text, tt.budget)
+		if tt.wantErr {
+			assert.True(t, errors.Is(err, ErrSplitLimitExceeded), name)
+			continue
+		}
+
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		assert.Equal(t, tt.want, got, name)
+
+		for _, chunk := range got {
+			assert.True(t, utf8.ValidString(chunk), name)
+			assert.LessOrEqual(t, escapedLen(chunk), tt.budget, name)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	newSection := func(text string) *Section {
+		section := NewSection()
+		section.Text = text
+		return section
+	}
+
+	tests := map[string]struct {
+		card    func(t *testing.T) *MessageCard
+		maxSize int
+		parts   int
+		wantErr bool
+		check   func(t *testing.T, card *MessageCard, parts []*MessageCard)
+	}{
+		"within size": {
+			card: func(t *testing.T) *MessageCard {
+				card := NewMessageCard()
+				card.Title = "Build log"
+				card.Text = "Done."
+				return card
+			},
+			maxSize: 1024,
+			parts:   1,
+			check: func(t *testing.T, card *MessageCard, parts []*MessageCard) {
+				assert.Same(t, card, parts[0])
+				assert.Equal(t, "Build log", parts[0].Title)
+			},
+		},
+		"section boundaries": {
+			card: func(t *testing.T) *MessageCard {
+				card := NewMessageCard()
+				card.Title = "Build log"
+				for i := 0; i < 3; i++ {
+					assert.NoError(t, card.AddSection(newSection(strings.Repeat(fmt.Sprint(i), 200))))
+				}
+				return card
+			},
+			maxSize: 600,
+			parts:   2,
+			check: func(t *testing.T, card *MessageCard, parts []*MessageCard) {
+				// Sections which fit are kept whole.
+				assert.Equal(t, card.Sections[:2], parts[0].Sections)
+				assert.Equal(t, card.Sections[2:], parts[1].Sections)
+			},
+		},
+		"long text with multi-byte runes": {
+			card: func(t *testing.T) *MessageCard {
+				card := NewMessageCard()
+				card.Title = "Build log"
+				card.Text = strings.Repeat("日本語 ", 100)
+				return card
+			},
+			maxSize: 512,
+			parts:   3,
+			check: func(t *testing.T, card *MessageCard, parts []*MessageCard) {
+				var text string
+				for _, part := range parts {
+					assert.True(t, utf8.ValidString(part.Text))
+					text += part.Text
+				}
+				assert.Equal(t, card.Text, text)
+			},
+		},
+		"section text and facts": {
+			card: func(t *testing.T) *MessageCard {
+				card := NewMessageCard()
+				section := newSection(strings.Repeat("x", 300))
+				section.Title = "Timings"
+				for i := 0; i < 20; i++ {
+					assert.NoError(t, section.AddFactFromKeyValue(fmt.Sprintf("Step %d", i), "1.5s"))
+				}
+				assert.NoError(t, card.AddSection(section))
+				return card
+			},
+			maxSize: 512,
+			parts:   3,
+			check: func(t *testing.T, card *MessageCard, parts []*MessageCard) {
+				assert.Equal(t, "Timings", parts[0].Sections[0].Title)
+
+				var facts []SectionFact
+				for _, part := range parts {
+					for _, section := range part.Sections {
+						facts = append(facts, section.Facts...)
+					}
+				}
+				assert.Equal(t, card.Sections[0].Facts, facts)
+			},
+		},
+		"section which cannot be split": {
+			card: func(t *testing.T) *MessageCard {
+				card := NewMessageCard()
+				section := NewSection()
+				assert.NoError(t, section.AddFactFromKeyValue("Output", strings.Repeat("x", 1024)))
+				assert.NoError(t, card.AddSection(section))
+				return card
+			},
+			maxSize: 512,
+			wantErr: true,
+		},
+		"part numbering": {
+			card: func(t *testing.T) *MessageCard {
+				card := NewMessageCard()
+				card.Text = strings.Repeat("line\n", 60)
+				assert.NoError(t, card.AddSection(newSection(strings.Repeat("x", 200))))
+				return card
+			},
+			maxSize: 400,
+			parts:   3,
+			check: func(t *testing.T, card *MessageCard, parts []*MessageCard) {
+				for i, part := range parts {
+					assert.Equal(t, fmt.Sprintf("(%d/%d)", i+1, len(parts)), part.Title)
+				}
+
+				// Parts without text are given a summary.
+				var withoutText int
+				for _, part := range parts {
+					if part.Text == "" {
+						withoutText++
+						assert.Equal(t, part.Title, part.Summary)
+					}
+				}
+				assert.NotZero(t, withoutText)
+				assert.Empty(t, card.Title)
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			card := tt.card(t)
+
+			parts, err := card.Split(tt.maxSize)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrSplitLimitExceeded))
+				return
+			}
+
+			if !assert.NoError(t, err) || !assert.Len(t, parts, tt.parts) {
+				return
+			}
+
+			for i, part := range parts {
+				b, err := json.Marshal(part)
+				assert.NoError(t, err)
+				assert.LessOrEqual(t, len(b), tt.maxSize)
+
+				if len(parts) > 1 {
+					assert.True(t, strings.HasSuffix(part.Title, fmt.Sprintf("(%d/%d)", i+1, len(parts))))
+				}
+			}
+
+			if tt.check != nil {
+				tt.check(t, card, parts)
+			}
+		})
+	}
+}
@@ -0,0 +1,453 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcome classifies the result of a message submission for metrics.
+type Outcome string
+
+// Outcomes reported to a MetricsRecorder.
+const (
+	// OutcomeSuccess indicates that the message was submitted successfully.
+	OutcomeSuccess Outcome = "success"
+
+	// OutcomeRateLimited indicates that the remote endpoint throttled the
+	// submission.
+	OutcomeRateLimited Outcome = "rate_limited"
+
+	// OutcomePayloadTooLarge indicates that the message was too large.
+	OutcomePayloadTooLarge Outcome = "payload_too_large"
+
+	// OutcomeWebhookNotFound indicates that the webhook URL is not known to
+	// the remote endpoint or its connector has been removed.
+	OutcomeWebhookNotFound Outcome = "webhook_not_found"
+
+	// OutcomeBadRequest indicates that the remote endpoint rejected the
+	// message.
+	OutcomeBadRequest Outcome = "bad_request"
+
+	// OutcomeServerError indicates that the remote endpoint failed to
+	// process the message.
+	OutcomeServerError Outcome = "server_error"
+
+	// OutcomeUnexpectedResponse indicates that the remote endpoint responded
+	// with unexpected response text.
+	OutcomeUnexpectedResponse Outcome = "unexpected_response"
+
+	// OutcomeNetworkError indicates that a response was not received.
+	OutcomeNetworkError Outcome = "network_error"
+
+	// OutcomeCancelled indicates that the context was cancelled or expired.
+	OutcomeCancelled Outcome = "cancelled"
+
+	// OutcomeCircuitOpen indicates that the submission was rejected by a
+	// CircuitBreaker.
+	OutcomeCircuitOpen Outcome = "circuit_open"
+
+	// OutcomeRejected indicates that the submission was not attempted due to
+	// a validation failure or an Interceptor.
+	OutcomeRejected Outcome = "rejected"
+)
+
+// ClassifyOutcome returns the Outcome describing the given error returned
+// from a message submission.
+func ClassifyOutcome(err error) Outcome {
+	var pErr *permanentError
+	var sendErr *SendError
+
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, ErrRateLimited):
+		return OutcomeRateLimited
+	case errors.Is(err, ErrPayloadTooLarge):
+		return OutcomePayloadTooLarge
+	case errors.Is(err, ErrWebhookNotFound):
+		return OutcomeWebhookNotFound
+	case errors.Is(err, ErrBadRequest):
+		return OutcomeBadRequest
+	case errors.Is(err, ErrServerError):
+		return OutcomeServerError
+	case errors.Is(err, ErrCircuitOpen):
+		return OutcomeCircuitOpen
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeCancelled
+	case errors.Is(err, ErrSendAborted), errors.As(err, &pErr):
+		return OutcomeRejected
+	case errors.As(err, &sendErr) && sendErr.StatusCode != 0:
+		return OutcomeUnexpectedResponse
+	default:
+		return OutcomeNetworkError
+	}
+}
+
+// AttemptMetrics describes a single message submission attempt.
+type AttemptMetrics struct {
+	// Host is the host portion of the webhook URL.
+	Host string
+
+	// Outcome is the result of the attempt.
+	Outcome Outcome
+
+	// Attempt is the number of the attempt, starting at 1.
+	Attempt int
+
+	// Duration is the time taken by the request to the remote endpoint, or
+	// zero if a request was not made.
+	Duration time.Duration
+
+	// PayloadSize is the size in bytes of the prepared message, or zero if
+	// the message was not prepared.
+	PayloadSize int
+}
+
+// SendMetrics describes a message submission including any retries.
+type SendMetrics struct {
+	// Host is the host portion of the webhook URL.
+	Host string
+
+	// Outcome is the result of the final attempt.
+	Outcome Outcome
+
+	// Attempts is the number of attempts made.
+	Attempts int
+
+	// Duration is the time taken by all attempts, including delays between
+	// them.
+	Duration time.Duration
+}
+
+// MetricsRecorder receives metrics for message submissions made by a
+// TeamsClient. Methods are called synchronously and may be called
+// concurrently; implementations should return quickly.
+type MetricsRecorder interface {
+	// RecordAttempt is called after each message submission attempt.
+	RecordAttempt(metrics AttemptMetrics)
+
+	// RecordSend is called once a message submission completes, after all
+	// attempts have been made.
+	RecordSend(metrics SendMetrics)
+}
+
+// SetMetricsRecorder accepts a MetricsRecorder which receives metrics for
+// each message submission.
+//
+// Deprecated: use the WithMetricsRecorder option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetMetricsRecorder(recorder MetricsRecorder) *TeamsClient {
+	c.metricsRecorder = recorder
+
+	return c
+}
+
+// MetricsRecorder returns the configured MetricsRecorder for the client or
+// nil if one has not been set.
+//
+// Deprecated: use TeamsClient.MetricsRecorder() method instead.
+func (c *teamsClient) MetricsRecorder() MetricsRecorder {
+	return nil
+}
+
+// MetricsRecorder returns the configured MetricsRecorder for the client or
+// nil if one has not been set.
+func (c *TeamsClient) MetricsRecorder() MetricsRecorder {
+	return c.metricsRecorder
+}
+
+// recordSend reports a completed message submission to the MetricsRecorder
+// for the client, if one is set.
+func recordSend(client MessageSender, webhookURL string, attempts int, duration time.Duration, err error) {
+	recorder := client.MetricsRecorder()
+	if recorder == nil {
+		return
+	}
+
+	recorder.RecordSend(SendMetrics{
+		Host:     webhookHost(webhookURL),
+		Outcome:  ClassifyOutcome(err),
+		Attempts: attempts,
+		Duration: duration,
+	})
+}
+
+// Histogram bucket upper bounds used by ExpvarMetrics.
+var (
+	durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	payloadBuckets  = []float64{1024, 4096, 8192, 16384, 28672, 65536}
+)
+
+// metricsKey identifies a series of metrics.
+type metricsKey struct {
+	host    string
+	outcome Outcome
+}
+
+// histogram tracks the distribution of observed values.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// newHistogram creates an empty histogram with the given bucket bounds.
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+// observe adds the given value to the histogram.
+func (h *histogram) observe(value float64) {
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.count++
+	h.sum += value
+}
+
+// ExpvarMetrics is a MetricsRecorder which aggregates metrics in memory. The
+// metrics are published as an expvar variable and may be served in the
+// Prometheus text exposition format using PrometheusHandler.
+type ExpvarMetrics struct {
+	mu           sync.Mutex
+	attempts     map[metricsKey]*histogram
+	sends        map[metricsKey]*histogram
+	retries      map[string]uint64
+	payloadSizes map[string]*histogram
+}
+
+// expvarPublishMu serializes publishing of expvar variables by
+// NewExpvarMetrics.
+var expvarPublishMu sync.Mutex
+
+// NewExpvarMetrics creates an ExpvarMetrics which is published as an expvar
+// variable with the given name, or not published if the name is empty. An
+// error is returned if a variable with the given name is already published.
+func NewExpvarMetrics(name string) (*ExpvarMetrics, error) {
+	m := &ExpvarMetrics{
+		attempts:     make(map[metricsKey]*histogram),
+		sends:        make(map[metricsKey]*histogram),
+		retries:      make(map[string]uint64),
+		payloadSizes: make(map[string]*histogram),
+	}
+
+	if name != "" {
+		// expvar.Publish panics if the name is already in use, so checking
+		// and publishing must not be interleaved with other constructors.
+		expvarPublishMu.Lock()
+		defer expvarPublishMu.Unlock()
+
+		if expvar.Get(name) != nil {
+			return nil, fmt.Errorf("expvar variable %q is already published", name)
+		}
+		expvar.Publish(name, expvar.Func(m.snapshot))
+	}
+
+	return m, nil
+}
+
+// RecordAttempt aggregates the given attempt metrics.
+func (m *ExpvarMetrics) RecordAttempt(metrics AttemptMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := metricsKey{host: metrics.Host, outcome: metrics.Outcome}
+	h, ok := m.attempts[key]
+	if !ok {
+		h = newHistogram(durationBuckets)
+		m.attempts[key] = h
+	}
+	h.observe(metrics.Duration.Seconds())
+
+	if metrics.Attempt > 1 {
+		m.retries[metrics.Host]++
+	}
+
+	// Record each message once regardless of the number of attempts.
+	if metrics.Attempt == 1 && metrics.PayloadSize > 0 {
+		p, ok := m.payloadSizes[metrics.Host]
+		if !ok {
+			p = newHistogram(payloadBuckets)
+			m.payloadSizes[metrics.Host] = p
+		}
+		p.observe(float64(metrics.PayloadSize))
+	}
+}
+
+// RecordSend aggregates the given message submission metrics.
+func (m *ExpvarMetrics) RecordSend(metrics SendMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := metricsKey{host: metrics.Host, outcome: metrics.Outcome}
+	h, ok := m.sends[key]
+	if !ok {
+		h = newHistogram(durationBuckets)
+		m.sends[key] = h
+	}
+	h.observe(metrics.Duration.Seconds())
+}
+
+// snapshot returns the current metrics in a form suitable for encoding as
+// an expvar variable.
+func (m *ExpvarMetrics) snapshot() interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	summarize := func(series map[metricsKey]*histogram) map[string]interface{} {
+		out := make(map[string]interface{}, len(series))
+		for key, h := range series {
+			out[key.host+"/"+string(key.outcome)] = map[string]interface{}{
+				"count":       h.count,
+				"sum_seconds": h.sum,
+			}
+		}
+		return out
+	}
+
+	retries := make(map[string]uint64, len(m.retries))
+	for host, n := range m.retries {
+		retries[host] = n
+	}
+
+	payloadSizes := make(map[string]interface{}, len(m.payloadSizes))
+	for host, h := range m.payloadSizes {
+		payloadSizes[host] = map[string]interface{}{
+			"count":     h.count,
+			"sum_bytes": h.sum,
+		}
+	}
+
+	return map[string]interface{}{
+		"attempts":      summarize(m.attempts),
+		"sends":         summarize(m.sends),
+		"retries":       retries,
+		"payload_bytes": payloadSizes,
+	}
+}
+
+// PrometheusHandler returns a http.Handler which serves the metrics in the
+// Prometheus text exposition format.
+func (m *ExpvarMetrics) PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes the metrics to the given io.Writer in the
+// Prometheus text exposition format.
+func (m *ExpvarMetrics) WritePrometheus(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeHistograms(w,
+		"teams_send_attempt_duration_seconds",
+		"Duration of message submission attempts.",
+		m.attempts,
+	)
+
+	writeHistograms(w,
+		"teams_send_duration_seconds",
+		"Duration of message submissions including retries.",
+		m.sends,
+	)
+
+	fmt.Fprintln(w, "# HELP teams_send_retries_total Number of message submission retries.")
+	fmt.Fprintln(w, "# TYPE teams_send_retries_total counter")
+	for _, host := range sortedHosts(m.retries) {
+		fmt.Fprintf(w, "teams_send_retries_total{host=%s} %d\n", quoteLabel(host), m.retries[host])
+	}
+
+	payloadSizes := make(map[metricsKey]*histogram, len(m.payloadSizes))
+	for host, h := range m.payloadSizes {
+		payloadSizes[metricsKey{host: host}] = h
+	}
+	writeHistograms(w,
+		"teams_send_payload_bytes",
+		"Size of prepared message payloads.",
+		payloadSizes,
+	)
+}
+
+// writeHistograms writes the given histogram series in the Prometheus text
+// exposition format. The outcome label is omitted for series without one.
+func writeHistograms(w io.Writer, name string, help string, series map[metricsKey]*histogram) {
+	keys := make([]metricsKey, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].host != keys[j].host {
+			return keys[i].host < keys[j].host
+		}
+		return keys[i].outcome < keys[j].outcome
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+
+	for _, key := range keys {
+		h := series[key]
+
+		labels := "host=" + quoteLabel(key.host)
+		if key.outcome != "" {
+			labels += ",outcome=" + quoteLabel(string(key.outcome))
+		}
+
+		for i, bound := range h.bounds {
+			fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, labels, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+// sortedHosts returns the sorted keys of the given map.
+func sortedHosts(m map[string]uint64) []string {
+	hosts := make([]string, 0, len(m))
+	for host := range m {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	return hosts
+}
+
+// quoteLabel quotes a Prometheus label value.
+func quoteLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+
+	return `"` + value + `"`
+}
+
+// formatFloat formats a value for the Prometheus text exposition format.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
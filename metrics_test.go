@@ -0,0 +1,119 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyOutcome(t *testing.T) {
+	tests := map[Outcome]error{
+		OutcomeSuccess:            nil,
+		OutcomeRateLimited:        fmt.Errorf("failed: %w", &SendError{StatusCode: http.StatusTooManyRequests}),
+		OutcomeServerError:        &SendError{StatusCode: http.StatusBadGateway},
+		OutcomeUnexpectedResponse: &SendError{StatusCode: http.StatusOK, ResponseText: "0", Err: ErrInvalidWebhookURLResponseText},
+		OutcomeNetworkError:       &SendError{Err: errors.New("connection reset by peer")},
+		OutcomeCancelled:          &SendError{Err: context.DeadlineExceeded},
+		OutcomeCircuitOpen:        &CircuitOpenError{},
+		OutcomeRejected:           &permanentError{errors.New("invalid message")},
+		OutcomePayloadTooLarge:    &permanentError{ErrPayloadTooLarge},
+	}
+
+	for want, err := range tests {
+		assert.Equal(t, want, ClassifyOutcome(err), "error: %v", err)
+	}
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	metrics, err := NewExpvarMetrics("goteamsnotify_test_metrics")
+	requireNoError(t, err)
+
+	_, err = NewExpvarMetrics("goteamsnotify_test_metrics")
+	assert.Error(t, err)
+
+	var requests int
+	client := NewTeamsClient().
+		SetMetricsRecorder(metrics).
+		SetRetryPolicy(NewConstantBackoff(2, time.Second)).
+		SetClock(&fakeClock{now: time.Now()}).
+		SetHTTPClient(newScriptedTestClient(&requests,
+			scriptedResponse{status: http.StatusTooManyRequests},
+			scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText},
+		))
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	assert.NoError(t, client.SendWithContext(context.Background(), "https://outlook.office.com/webhook/xxx", &msgCard))
+
+	server := httptest.NewServer(metrics.PrometheusHandler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	requireNoError(t, err)
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	requireNoError(t, err)
+	output := string(body)
+
+	assert.Contains(t, res.Header.Get("Content-Type"), "text/plain")
+	assert.Contains(t, output, "# TYPE teams_send_attempt_duration_seconds histogram\n")
+	assert.Contains(t, output, `teams_send_attempt_duration_seconds_count{host="outlook.office.com",outcome="rate_limited"} 1`)
+	assert.Contains(t, output, `teams_send_attempt_duration_seconds_count{host="outlook.office.com",outcome="success"} 1`)
+	assert.Contains(t, output, `teams_send_duration_seconds_bucket{host="outlook.office.com",outcome="success",le="0.5"} 0`)
+	assert.Contains(t, output, `teams_send_duration_seconds_bucket{host="outlook.office.com",outcome="success",le="1"} 1`)
+	assert.Contains(t, output, `teams_send_retries_total{host="outlook.office.com"} 1`)
+	assert.Contains(t, output, `teams_send_payload_bytes_count{host="outlook.office.com"} 1`)
+
+	published := expvar.Get("goteamsnotify_test_metrics")
+	if assert.NotNil(t, published) {
+		assert.Contains(t, published.String(), `"outlook.office.com/success"`)
+	}
+}
+
+func TestNewExpvarMetricsConcurrent(t *testing.T) {
+	const constructors = 10
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, constructors)
+	for i := 0; i < constructors; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := NewExpvarMetrics("goteamsnotify_test_concurrent_metrics")
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	// Exactly one constructor publishes the variable; the others fail
+	// without panicking.
+	var published int
+	for err := range errs {
+		if err == nil {
+			published++
+		}
+	}
+	assert.Equal(t, 1, published)
+}
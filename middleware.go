@@ -0,0 +1,105 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrSendAborted indicates that an Interceptor stopped a message submission.
+// Interceptors should wrap this error (e.g., fmt.Errorf("blocked in staging:
+// %w", ErrSendAborted)) when short-circuiting a submission which should not
+// be retried.
+var ErrSendAborted = errors.New("message submission aborted by interceptor")
+
+// SendRequest describes a single message submission attempt as it passes
+// through the Interceptor chain registered with a TeamsClient.
+type SendRequest struct {
+	// WebhookURL is the webhook URL that the message is submitted to.
+	WebhookURL string
+
+	// Message is the message being submitted.
+	Message Message
+
+	// Payload is the prepared message payload used as the request body.
+	// Changes to Payload do not affect HTTPRequest.
+	Payload []byte
+
+	// HTTPRequest is the request submitted to the remote endpoint.
+	// Interceptors may modify the request (e.g., to add headers) or replace
+	// it before calling the next SendHandler.
+	HTTPRequest *http.Request
+
+	// IdempotencyKey is the key given using SendIdempotencyKey, if any.
+	IdempotencyKey string
+}
+
+// SendHandler submits a message described by the given SendRequest and
+// returns the response from the remote endpoint. The response body is
+// processed (and closed) by the TeamsClient.
+type SendHandler func(req *SendRequest) (*http.Response, error)
+
+// Interceptor is called for each message submission attempt made by a
+// TeamsClient. An Interceptor calls next to continue the submission and may
+// inspect or modify the request beforehand and the response and error
+// afterwards. An Interceptor which returns without calling next
+// short-circuits the submission; a non-nil response returned in this way is
+// processed as if it were returned by the remote endpoint. Returning neither
+// a response nor an error aborts the submission with an error matching
+// ErrSendAborted.
+//
+// An Interceptor must not consume the response body unless it replaces the
+// body with one providing the same content.
+type Interceptor func(req *SendRequest, next SendHandler) (*http.Response, error)
+
+// Use registers the given interceptors which are applied to each message
+// submission attempt.
+//
+// Deprecated: use the WithInterceptors option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) Use(interceptors ...Interceptor) *TeamsClient {
+	// The slice is copied so that interceptors previously returned by the
+	// client are not modified.
+	c.interceptors = append(c.interceptors[:len(c.interceptors):len(c.interceptors)], interceptors...)
+
+	return c
+}
+
+// Interceptors returns the registered interceptors for the client.
+func (c *TeamsClient) Interceptors() []Interceptor {
+	return c.interceptors
+}
+
+// attemptInterceptors returns the interceptors applied to each message
+// submission attempt. Interceptors are not supported by the legacy client.
+func (c *teamsClient) attemptInterceptors() []Interceptor {
+	return nil
+}
+
+// attemptInterceptors returns the interceptors applied to each message
+// submission attempt.
+func (c *TeamsClient) attemptInterceptors() []Interceptor {
+	return c.interceptors
+}
+
+// chainInterceptors returns a SendHandler which applies the given
+// interceptors in order before calling the given final SendHandler.
+func chainInterceptors(interceptors []Interceptor, final SendHandler) SendHandler {
+	handler := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor := interceptors[i]
+		next := handler
+
+		handler = func(req *SendRequest) (*http.Response, error) {
+			return interceptor(req, next)
+		}
+	}
+
+	return handler
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeamsClientInterceptors(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+
+	var gotHeader string
+	httpClient := NewTestClient(func(req *http.Request) (*http.Response, error) {
+		gotHeader = req.Header.Get("X-Correlation-ID")
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(ExpectedWebhookURLResponseText)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	var calls []string
+	var audited []byte
+	var observedStatus int
+
+	client := NewTeamsClient().
+		SetHTTPClient(httpClient).
+		Use(
+			func(req *SendRequest, next SendHandler) (*http.Response, error) {
+				calls = append(calls, "audit")
+				audited = req.Payload
+
+				res, err := next(req)
+				if res != nil {
+					observedStatus = res.StatusCode
+				}
+
+				return res, err
+			},
+			func(req *SendRequest, next SendHandler) (*http.Response, error) {
+				calls = append(calls, "correlate")
+				assert.Equal(t, webhookURL, req.WebhookURL)
+				req.HTTPRequest.Header.Set("X-Correlation-ID", "abc123")
+
+				return next(req)
+			},
+		)
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	assert.NoError(t, client.SendWithContext(context.Background(), webhookURL, &msgCard))
+	assert.Equal(t, []string{"audit", "correlate"}, calls)
+	assert.Equal(t, "abc123", gotHeader)
+	assert.Equal(t, http.StatusOK, observedStatus)
+	assert.JSONEq(t, `{"@type":"MessageCard","@context":"https://schema.org/extensions","text":"Hello World"}`, string(audited))
+}
+
+func TestTeamsClientInterceptorShortCircuit(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+
+	var requests int
+	client := NewTeamsClient().
+		SetRetryPolicy(NewConstantBackoff(3, time.Second)).
+		SetClock(&fakeClock{now: time.Now()}).
+		SetHTTPClient(newScriptedTestClient(&requests, scriptedResponse{
+			status: http.StatusOK,
+			body:   ExpectedWebhookURLResponseText,
+		})).
+		Use(func(req *SendRequest, next SendHandler) (*http.Response, error) {
+			return nil, fmt.Errorf("sends disabled in staging: %w", ErrSendAborted)
+		})
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	err := client.SendWithContext(context.Background(), webhookURL, &msgCard)
+	assert.True(t, errors.Is(err, ErrSendAborted))
+	assert.False(t, IsRetryableError(err))
+	assert.Equal(t, 0, requests)
+
+	// A response returned by an interceptor is processed as usual.
+	client.interceptors = nil
+	client.Use(func(req *SendRequest, next SendHandler) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Status:     "400 Bad Request",
+			Body:       ioutil.NopCloser(bytes.NewBufferString("Summary or Text is required.")),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	err = client.SendWithContext(context.Background(), webhookURL, &msgCard)
+	assert.True(t, errors.Is(err, ErrBadRequest))
+	assert.Equal(t, 0, requests)
+}
+
+func TestTeamsClientInterceptorNoResponse(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+
+	var calls int
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1})
+	client := NewTeamsClient(
+		WithRetryPolicy(NewConstantBackoff(3, time.Second)),
+		WithClock(&fakeClock{now: time.Now()}),
+		WithCircuitBreaker(breaker),
+		WithInterceptors(func(req *SendRequest, next SendHandler) (*http.Response, error) {
+			calls++
+
+			return nil, nil
+		}),
+	)
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	err := client.SendWithContext(context.Background(), webhookURL, &msgCard)
+	assert.True(t, errors.Is(err, ErrSendAborted))
+	assert.False(t, IsRetryableError(err))
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, CircuitClosed, breaker.State(webhookURL))
+}
+
+func TestTeamsClientInterceptorPayloadCopy(t *testing.T) {
+	var body []byte
+	client := NewTeamsClient(
+		WithHTTPClient(NewTestClient(func(req *http.Request) (*http.Response, error) {
+			var err error
+			body, err = ioutil.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(ExpectedWebhookURLResponseText)),
+				Header:     make(http.Header),
+			}, nil
+		})),
+		WithInterceptors(func(req *SendRequest, next SendHandler) (*http.Response, error) {
+			for i := range req.Payload {
+				req.Payload[i] = 'X'
+			}
+
+			return next(req)
+		}),
+	)
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	assert.NoError(t, client.Send("https://outlook.office.com/webhook/xxx", &msgCard))
+	assert.Contains(t, string(body), "Hello World")
+}
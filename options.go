@@ -0,0 +1,203 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures a TeamsClient. Options are applied by NewTeamsClient
+// and TeamsClient.With.
+type Option func(c *TeamsClient)
+
+// WithHTTPClient sets the http.Client used to submit messages. The
+// http.Client is not copied; it is shared with clients derived using
+// TeamsClient.With.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *TeamsClient) {
+		c.HttpClient = httpClient
+	}
+}
+
+// WithUserAgent sets the user agent used when submitting messages.
+func WithUserAgent(userAgent string) Option {
+	return func(c *TeamsClient) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithWebhookURLValidationPatterns adds the given patterns for validation of
+// webhook URLs.
+func WithWebhookURLValidationPatterns(patterns ...string) Option {
+	return func(c *TeamsClient) {
+		c.webhookURLValidationPatterns = append(c.webhookURLValidationPatterns, compileWebhookPatterns(patterns)...)
+	}
+}
+
+// WithSkipWebhookURLValidation allows the caller to optionally disable
+// webhook URL validation when submitting messages.
+func WithSkipWebhookURLValidation(skip bool) Option {
+	return func(c *TeamsClient) {
+		c.skipWebhookURLValidation = skip
+	}
+}
+
+// WithWebhookValidator sets the WebhookValidator used in place of webhook
+// URL validation patterns. Validation may still be disabled using
+// WithSkipWebhookURLValidation. If not set (or set to nil), validation
+// patterns are used.
+func WithWebhookValidator(validator *WebhookValidator) Option {
+	return func(c *TeamsClient) {
+		c.webhookValidator = validator
+	}
+}
+
+// WithSendTimeout sets how long the Send method may take before it times out
+// and is cancelled. A zero value applies DefaultWebhookSendTimeout.
+func WithSendTimeout(timeout time.Duration) Option {
+	return func(c *TeamsClient) {
+		c.sendTimeout = timeout
+	}
+}
+
+// WithRetryPolicy sets the RetryPolicy applied when submitting messages via
+// the Send and SendWithContext methods. If not set (or set to nil), a single
+// attempt is made to submit a message.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *TeamsClient) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithClock sets the Clock used to apply delays between retry attempts and
+// to measure the time taken by send attempts. Context deadlines are always
+// measured using the system clock.
+func WithClock(clock Clock) Option {
+	return func(c *TeamsClient) {
+		c.clock = clock
+	}
+}
+
+// WithLogger sets the Logger which replaces the package logger for log
+// entries related to the client. Webhook URLs are redacted in log entries
+// unless WithLogWebhookURLs is used to include them.
+func WithLogger(logger Logger) Option {
+	return func(c *TeamsClient) {
+		c.logger = logger
+	}
+}
+
+// WithLogWebhookURLs allows the caller to optionally include full webhook
+// URLs in log entries.
+func WithLogWebhookURLs(include bool) Option {
+	return func(c *TeamsClient) {
+		c.logWebhookURLs = include
+	}
+}
+
+// WithRateLimiter sets the RateLimiter applied to each message submission
+// attempt in order to stay within the request limits applied by Microsoft
+// Teams for each webhook URL. If not set (or set to nil), message
+// submissions are not rate limited.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *TeamsClient) {
+		c.rateLimiter = limiter
+	}
+}
+
+// WithCircuitBreaker sets the CircuitBreaker applied to each message
+// submission attempt in order to fail fast when a webhook URL is
+// consistently failing. If not set (or set to nil), a circuit breaker is not
+// used.
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(c *TeamsClient) {
+		c.circuitBreaker = breaker
+	}
+}
+
+// WithMaxPayloadSize sets the maximum size in bytes of a prepared message
+// payload. Messages exceeding this size are not submitted; an error matching
+// ErrPayloadTooLarge is returned instead unless splitting of oversized
+// messages is enabled. The size is not checked by default or if set to zero
+// or a negative value; DefaultMaxPayloadSize is the recommended maximum.
+func WithMaxPayloadSize(size int) Option {
+	return func(c *TeamsClient) {
+		c.maxPayloadSize = size
+	}
+}
+
+// WithSplitOversizedMessages allows the caller to optionally enable
+// splitting of oversized messages. If enabled, a *messagecard.MessageCard
+// which exceeds the maximum payload size is split into numbered cards which
+// are submitted in order. Other message formats are not split.
+func WithSplitOversizedMessages(split bool) Option {
+	return func(c *TeamsClient) {
+		c.splitOversizedMessages = split
+	}
+}
+
+// WithFanOutConcurrency sets the number of webhook URLs that a message is
+// submitted to concurrently by SendToMany.
+func WithFanOutConcurrency(concurrency int) Option {
+	return func(c *TeamsClient) {
+		c.fanOutConcurrency = concurrency
+	}
+}
+
+// WithInterceptors adds the given interceptors which are applied to each
+// message submission attempt. Interceptors are applied in the order added;
+// the first added Interceptor is the first to be called.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(c *TeamsClient) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// WithMetricsRecorder sets the MetricsRecorder which receives metrics for
+// each message submission. If not set (or set to nil), metrics are not
+// recorded.
+func WithMetricsRecorder(recorder MetricsRecorder) Option {
+	return func(c *TeamsClient) {
+		c.metricsRecorder = recorder
+	}
+}
+
+// WithDryRun sets the DryRun which replaces submission of messages to the
+// webhook URL. If not set (or set to nil), messages are submitted normally.
+func WithDryRun(dryRun *DryRun) Option {
+	return func(c *TeamsClient) {
+		c.dryRun = dryRun
+	}
+}
+
+// With returns a copy of the client with the given options applied. The
+// client is not modified, so With may be called while the client is used to
+// submit messages from other goroutines. The copy shares the http.Client,
+// RateLimiter, CircuitBreaker and other referenced values of the client.
+func (c *TeamsClient) With(opts ...Option) *TeamsClient {
+	clone := *c
+	clone.webhookURLValidationPatterns = append([]webhookPattern(nil), c.webhookURLValidationPatterns...)
+	clone.interceptors = append([]Interceptor(nil), c.interceptors...)
+
+	for _, opt := range opts {
+		opt(&clone)
+	}
+
+	return &clone
+}
+
+// SendTimeout returns how long the Send method may take before it times out
+// and is cancelled.
+func (c *TeamsClient) SendTimeout() time.Duration {
+	if c.sendTimeout <= 0 {
+		return DefaultWebhookSendTimeout
+	}
+
+	return c.sendTimeout
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTeamsClientOptions(t *testing.T) {
+	httpClient := &http.Client{}
+	policy := NewConstantBackoff(2, time.Second)
+	logger := &recordingLogger{}
+
+	client := NewTeamsClient(
+		WithHTTPClient(httpClient),
+		WithUserAgent("custom/1.0"),
+		WithRetryPolicy(policy),
+		WithLogger(logger),
+		WithSendTimeout(time.Minute),
+		WithMaxPayloadSize(1024),
+		WithWebhookURLValidationPatterns(`^https://example\.com/`),
+	)
+
+	assert.Equal(t, httpClient, client.HTTPClient())
+	assert.Equal(t, "custom/1.0", client.UserAgent())
+	assert.Equal(t, policy, client.RetryPolicy())
+	assert.Equal(t, logger, client.Logger())
+	assert.Equal(t, time.Minute, client.SendTimeout())
+	assert.Equal(t, 1024, client.MaxPayloadSize())
+	assert.NoError(t, client.ValidateWebhook("https://example.com/webhook"))
+
+	// No options gives a minimal client.
+	client = NewTeamsClient()
+	assert.Equal(t, DefaultUserAgent, client.UserAgent())
+	assert.Equal(t, DefaultWebhookSendTimeout, client.SendTimeout())
+	assert.Nil(t, client.RetryPolicy())
+}
+
+func TestTeamsClientWith(t *testing.T) {
+	client := NewTeamsClient(
+		WithUserAgent("custom/1.0"),
+		WithWebhookURLValidationPatterns(`^https://example\.com/`),
+	)
+
+	noop := func(req *SendRequest, next SendHandler) (*http.Response, error) {
+		return next(req)
+	}
+
+	derived := client.With(
+		WithUserAgent("derived/1.0"),
+		WithWebhookURLValidationPatterns(`^https://example\.org/`),
+		WithInterceptors(noop),
+	)
+
+	assert.Equal(t, "derived/1.0", derived.UserAgent())
+	assert.NoError(t, derived.ValidateWebhook("https://example.org/webhook"))
+	assert.Len(t, derived.Interceptors(), 1)
+
+	assert.Equal(t, "custom/1.0", client.UserAgent())
+	assert.True(t, errors.Is(client.ValidateWebhook("https://example.org/webhook"), ErrWebhookURLUnexpected))
+	assert.Empty(t, client.Interceptors())
+
+	// Interceptors previously returned by a client are not modified by Use.
+	client.interceptors = make([]Interceptor, 0, 1)
+	interceptors := client.Interceptors()
+	client.Use(noop)
+	assert.Nil(t, interceptors[:1][0])
+}
+
+func TestTeamsClientWithConcurrentSend(t *testing.T) {
+	var mu sync.Mutex
+	userAgents := make(map[string]int)
+
+	client := NewTeamsClient(WithHTTPClient(NewTestClient(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		userAgents[req.Header.Get("User-Agent")]++
+		mu.Unlock()
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(ExpectedWebhookURLResponseText)),
+			Header:     make(http.Header),
+		}, nil
+	})))
+
+	msgCard := newTestMessageCard(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, client.Send("https://outlook.office.com/webhook/xxx", msgCard))
+		}()
+		go func() {
+			defer wg.Done()
+			derived := client.With(WithUserAgent("derived/1.0"))
+			assert.NoError(t, derived.Send("https://outlook.office.com/webhook/xxx", msgCard))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]int{DefaultUserAgent: 8, "derived/1.0": 8}, userAgents)
+}
+
+func TestTeamsClientSendTimeout(t *testing.T) {
+	client := NewTeamsClient(
+		WithSendTimeout(10*time.Millisecond),
+		WithHTTPClient(NewTestClient(func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+
+			return nil, req.Context().Err()
+		})),
+	)
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	err := client.Send("https://outlook.office.com/webhook/xxx", &msgCard)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
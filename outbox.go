@@ -0,0 +1,718 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default settings applied by an Outbox unless overridden.
+const (
+	// DefaultOutboxSegmentSize is the default size (in bytes) at which a new
+	// outbox segment file is started.
+	DefaultOutboxSegmentSize int64 = 4 * 1024 * 1024
+)
+
+// Outbox segment file naming.
+const (
+	outboxSegmentPrefix = "outbox-"
+	outboxSegmentSuffix = ".log"
+)
+
+// Outbox record operations.
+const (
+	outboxOpAdd  = "add"
+	outboxOpDone = "done"
+	outboxOpDrop = "drop"
+)
+
+// Reasons recorded when an outbox entry is dropped without being delivered.
+const (
+	outboxDropExpired   = "expired"
+	outboxDropPurged    = "purged"
+	outboxDropPermanent = "permanent failure"
+)
+
+// ErrOutboxClosed is returned when an operation is attempted on a closed
+// Outbox.
+var ErrOutboxClosed = errors.New("outbox is closed")
+
+// OutboxConfig provides settings for an Outbox. Default values are used for
+// any zero value fields.
+type OutboxConfig struct {
+	// MaxAge is how long a message may remain pending before it is dropped
+	// instead of delivered. A zero value indicates that messages do not
+	// expire.
+	MaxAge time.Duration
+
+	// SegmentSize is the size (in bytes) at which a new segment file is
+	// started.
+	SegmentSize int64
+}
+
+// OutboxEntry is a message pending delivery from an Outbox.
+type OutboxEntry struct {
+	// ID uniquely identifies the entry within the Outbox.
+	ID uint64
+
+	// WebhookURL is the destination of the message.
+	WebhookURL string
+
+	// Payload is the prepared message.
+	Payload []byte
+
+	// Created is the time that the message was added to the Outbox.
+	Created time.Time
+
+	// IdempotencyKey is the key given using SendIdempotencyKey, if any.
+	IdempotencyKey string
+
+	// skipValidation indicates that webhook URL validation was disabled
+	// using SendSkipWebhookURLValidation.
+	skipValidation bool
+}
+
+// outboxRecord is a single line in an outbox segment file.
+type outboxRecord struct {
+	Op             string          `json:"op"`
+	ID             uint64          `json:"id"`
+	WebhookURL     string          `json:"webhookURL,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	Created        int64           `json:"created,omitempty"`
+	Reason         string          `json:"reason,omitempty"`
+	Key            string          `json:"key,omitempty"`
+	SkipValidation bool            `json:"skipValidation,omitempty"`
+}
+
+// outboxSegment is an append-only outbox segment file.
+type outboxSegment struct {
+	seq  int
+	path string
+
+	// live is the number of pending entries added in this segment.
+	live int
+}
+
+// outboxEntry tracks a pending entry along with the segment it was added
+// in.
+type outboxEntry struct {
+	OutboxEntry
+	segment *outboxSegment
+}
+
+// Outbox provides at-least-once delivery of messages across process
+// restarts. Messages are persisted to an append-only log of segment files in
+// a directory before they are submitted and are marked as done once
+// successfully delivered. Messages still pending when a process exits are
+// delivered by calling Replay after the Outbox is opened again.
+//
+// Persisted entries include the full webhook URL; the directory should be
+// protected accordingly.
+//
+// An Outbox is safe for concurrent use, but a directory should only be used
+// by a single Outbox at a time.
+type Outbox struct {
+	client *TeamsClient
+	dir    string
+	config OutboxConfig
+
+	mu       sync.Mutex
+	segments []*outboxSegment
+	current  *os.File
+	size     int64
+	entries  map[uint64]*outboxEntry
+	inflight map[uint64]bool
+	nextID   uint64
+	closed   bool
+}
+
+// OpenOutbox opens (creating if needed) an Outbox in the given directory
+// which delivers messages using the given TeamsClient. Any entries still
+// pending from a previous process are loaded and may be delivered by calling
+// Replay.
+func OpenOutbox(dir string, client *TeamsClient, config OutboxConfig) (*Outbox, error) {
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = DefaultOutboxSegmentSize
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	o := Outbox{
+		client:   client,
+		dir:      dir,
+		config:   config,
+		entries:  make(map[uint64]*outboxEntry),
+		inflight: make(map[uint64]bool),
+		nextID:   1,
+	}
+
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+
+	// New records are always written to a new segment so that a partially
+	// written record from a previous process is never appended to.
+	if err := o.rotate(); err != nil {
+		return nil, err
+	}
+
+	o.compact()
+
+	return &o, nil
+}
+
+// Send persists the given message to the Outbox and then submits it to the
+// given webhook URL. The message is marked as done if successfully
+// delivered. If delivery fails with an error which is retryable (see
+// IsRetryableError), the message remains pending for a later Replay.
+func (o *Outbox) Send(ctx context.Context, webhookURL string, message Message) error {
+	return o.SendWithOptions(ctx, webhookURL, message)
+}
+
+// SendWithOptions persists the given message to the Outbox and then submits
+// it in the same manner as Send, applying the given options; see
+// TeamsClient.SendWithOptions. If a key is given using SendIdempotencyKey
+// and a message with the same key is already pending for the webhook URL,
+// the message is not persisted or submitted again and nil is returned. Only
+// the key and whether webhook URL validation is skipped are persisted; other
+// options are not applied when pending messages are delivered by Replay.
+// Messages are not split when the client has a maximum payload size set;
+// ErrPayloadTooLarge is returned for larger messages instead.
+func (o *Outbox) SendWithOptions(ctx context.Context, webhookURL string, message Message, opts ...SendOption) error {
+	options := newSendOptions(opts)
+
+	if !options.skipValidation {
+		if err := o.client.ValidateWebhook(webhookURL); err != nil {
+			return fmt.Errorf(
+				"failed to validate webhook URL: %w",
+				err,
+			)
+		}
+	}
+
+	if err := message.Validate(); err != nil {
+		return fmt.Errorf(
+			"failed to validate message: %w",
+			err,
+		)
+	}
+
+	snapshot, err := snapshotMessage(message)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to prepare message: %w",
+			err,
+		)
+	}
+
+	payload, err := preparedPayload(snapshot)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to retrieve prepared message: %w",
+			err,
+		)
+	}
+
+	// Pending messages are delivered as-is and cannot be split later, so
+	// messages which exceed the maximum payload size are not persisted.
+	if limit := o.client.MaxPayloadSize(); limit > 0 {
+		size, err := submittedSize(webhookURL, snapshot)
+		if err != nil {
+			return fmt.Errorf(
+				"failed to retrieve prepared message: %w",
+				err,
+			)
+		}
+
+		if size > limit {
+			return &permanentError{fmt.Errorf(
+				"prepared message is %d bytes, exceeding limit of %d bytes: %w",
+				size,
+				limit,
+				ErrPayloadTooLarge,
+			)}
+		}
+	}
+
+	entry, added, err := o.add(webhookURL, payload, options.idempotencyKey, options.skipValidation)
+	if err != nil {
+		return err
+	}
+
+	if !added {
+		o.client.Logger().Debug("message already pending in outbox", "id", entry.ID)
+
+		return nil
+	}
+
+	return o.deliver(ctx, entry, opts)
+}
+
+// Replay submits all pending messages in the order they were added. Messages
+// older than the configured maximum age are dropped instead. The number of
+// messages delivered is returned along with the first error encountered;
+// pending messages remain pending if delivery fails with a retryable error.
+func (o *Outbox) Replay(ctx context.Context) (int, error) {
+	if _, err := o.Expire(); err != nil {
+		return 0, err
+	}
+
+	var delivered int
+	var firstErr error
+
+	for _, entry := range o.claimPending() {
+		if ctx.Err() != nil {
+			o.release(entry.ID)
+			if firstErr == nil {
+				firstErr = ctx.Err()
+			}
+			continue
+		}
+
+		err := o.deliver(ctx, entry, nil)
+		switch {
+		case err == nil:
+			delivered++
+		case firstErr == nil:
+			firstErr = err
+		}
+	}
+
+	return delivered, firstErr
+}
+
+// Pending returns the messages pending delivery in the order they were
+// added.
+func (o *Outbox) Pending() []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	pending := make([]OutboxEntry, 0, len(o.entries))
+	for _, entry := range o.entries {
+		e := entry.OutboxEntry
+		e.Payload = append([]byte(nil), entry.Payload...)
+		pending = append(pending, e)
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].ID < pending[j].ID
+	})
+
+	return pending
+}
+
+// Purge drops pending messages for which the given function returns true,
+// or all pending messages if the function is nil. Messages currently being
+// delivered are not affected. The number of messages dropped is returned.
+func (o *Outbox) Purge(match func(entry OutboxEntry) bool) (int, error) {
+	return o.drop(outboxDropPurged, match)
+}
+
+// Expire drops pending messages older than the configured maximum age. The
+// number of messages dropped is returned.
+func (o *Outbox) Expire() (int, error) {
+	if o.config.MaxAge <= 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-o.config.MaxAge)
+
+	return o.drop(outboxDropExpired, func(entry OutboxEntry) bool {
+		return entry.Created.Before(cutoff)
+	})
+}
+
+// Close closes the current segment file. Pending messages remain on disk
+// for delivery by a later Outbox using the same directory.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+
+	return o.current.Close()
+}
+
+// deliver submits a pending entry using the given options and records the
+// outcome.
+func (o *Outbox) deliver(ctx context.Context, entry OutboxEntry, opts []SendOption) error {
+	if entry.IdempotencyKey != "" {
+		opts = append(opts[:len(opts):len(opts)], SendIdempotencyKey(entry.IdempotencyKey))
+	}
+
+	if entry.skipValidation {
+		opts = append(opts[:len(opts):len(opts)], SendSkipWebhookURLValidation())
+	}
+
+	err := o.client.SendWithOptions(ctx, entry.WebhookURL, &RawMessage{payload: entry.Payload}, opts...)
+
+	var recordErr error
+	switch {
+	case err == nil:
+		recordErr = o.settle(entry.ID, outboxRecord{Op: outboxOpDone, ID: entry.ID})
+	case !IsRetryableError(err):
+		recordErr = o.settle(entry.ID, outboxRecord{Op: outboxOpDrop, ID: entry.ID, Reason: outboxDropPermanent})
+	default:
+		o.release(entry.ID)
+	}
+
+	if recordErr != nil {
+		o.client.Logger().Error("failed to record outbox entry outcome", "id", entry.ID, "error", recordErr)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return recordErr
+}
+
+// add persists a new pending entry and marks it as in flight. If the given
+// key is not empty and an entry with the same key is already pending for the
+// webhook URL, that entry is returned instead and added is false.
+func (o *Outbox) add(webhookURL string, payload []byte, key string, skipValidation bool) (entry OutboxEntry, added bool, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return OutboxEntry{}, false, ErrOutboxClosed
+	}
+
+	if key != "" {
+		for _, pending := range o.entries {
+			if pending.IdempotencyKey == key && pending.WebhookURL == webhookURL {
+				return pending.OutboxEntry, false, nil
+			}
+		}
+	}
+
+	newEntry := outboxEntry{
+		OutboxEntry: OutboxEntry{
+			ID:             o.nextID,
+			WebhookURL:     webhookURL,
+			Payload:        payload,
+			Created:        time.Now(),
+			IdempotencyKey: key,
+			skipValidation: skipValidation,
+		},
+	}
+
+	record := outboxRecord{
+		Op:             outboxOpAdd,
+		ID:             newEntry.ID,
+		WebhookURL:     webhookURL,
+		Payload:        payload,
+		Created:        newEntry.Created.UnixNano(),
+		Key:            key,
+		SkipValidation: skipValidation,
+	}
+
+	// The entry must be durable before delivery is attempted.
+	if err := o.write(record, true); err != nil {
+		return OutboxEntry{}, false, err
+	}
+
+	o.nextID++
+	newEntry.segment = o.segments[len(o.segments)-1]
+	newEntry.segment.live++
+	o.entries[newEntry.ID] = &newEntry
+	o.inflight[newEntry.ID] = true
+
+	return newEntry.OutboxEntry, true, nil
+}
+
+// settle records the final outcome for a pending entry and removes it from
+// the index.
+func (o *Outbox) settle(id uint64, record outboxRecord) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.inflight, id)
+
+	entry, ok := o.entries[id]
+	if !ok {
+		return nil
+	}
+
+	if o.closed {
+		return ErrOutboxClosed
+	}
+
+	if err := o.write(record, false); err != nil {
+		return err
+	}
+
+	delete(o.entries, id)
+	entry.segment.live--
+	o.compact()
+
+	return nil
+}
+
+// drop settles pending entries (not in flight) matching the given function
+// with a drop record using the given reason.
+func (o *Outbox) drop(reason string, match func(entry OutboxEntry) bool) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return 0, ErrOutboxClosed
+	}
+
+	var dropped int
+	for id, entry := range o.entries {
+		if o.inflight[id] || (match != nil && !match(entry.OutboxEntry)) {
+			continue
+		}
+
+		if err := o.write(outboxRecord{Op: outboxOpDrop, ID: id, Reason: reason}, false); err != nil {
+			return dropped, err
+		}
+
+		delete(o.entries, id)
+		entry.segment.live--
+		dropped++
+	}
+
+	o.compact()
+
+	return dropped, nil
+}
+
+// claimPending marks all pending entries which are not already in flight as
+// in flight and returns them in the order they were added.
+func (o *Outbox) claimPending() []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	claimed := make([]OutboxEntry, 0, len(o.entries))
+	for id, entry := range o.entries {
+		if o.inflight[id] {
+			continue
+		}
+
+		o.inflight[id] = true
+		claimed = append(claimed, entry.OutboxEntry)
+	}
+
+	sort.Slice(claimed, func(i, j int) bool {
+		return claimed[i].ID < claimed[j].ID
+	})
+
+	return claimed
+}
+
+// release marks a pending entry as no longer in flight.
+func (o *Outbox) release(id uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.inflight, id)
+}
+
+// write appends a record to the current segment, starting a new segment if
+// the size limit is reached. The caller must hold the lock.
+func (o *Outbox) write(record outboxRecord, sync bool) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox record: %w", err)
+	}
+	line = append(line, '\n')
+
+	if o.size > 0 && o.size+int64(len(line)) > o.config.SegmentSize {
+		if err := o.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := o.current.Write(line)
+	o.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write outbox record: %w", err)
+	}
+
+	if sync {
+		if err := o.current.Sync(); err != nil {
+			return fmt.Errorf("failed to sync outbox segment: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// rotate closes the current segment file (if any) and starts a new one. The
+// caller must hold the lock (if the Outbox is in use).
+func (o *Outbox) rotate() error {
+	seq := 1
+	if len(o.segments) > 0 {
+		seq = o.segments[len(o.segments)-1].seq + 1
+	}
+
+	segment := outboxSegment{
+		seq:  seq,
+		path: filepath.Join(o.dir, fmt.Sprintf("%s%08d%s", outboxSegmentPrefix, seq, outboxSegmentSuffix)),
+	}
+
+	f, err := os.OpenFile(segment.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox segment: %w", err)
+	}
+
+	if o.current != nil {
+		if err := o.current.Close(); err != nil {
+			o.client.Logger().Warn("failed to close outbox segment", "error", err)
+		}
+	}
+
+	o.current = f
+	o.size = 0
+	o.segments = append(o.segments, &segment)
+
+	return nil
+}
+
+// compact removes the oldest segment files once none of the entries added
+// in them remain pending. Segments are only removed in order so that records
+// settling an entry are never removed before the entry itself. The caller
+// must hold the lock (if the Outbox is in use).
+func (o *Outbox) compact() {
+	for len(o.segments) > 1 && o.segments[0].live == 0 {
+		if err := os.Remove(o.segments[0].path); err != nil && !os.IsNotExist(err) {
+			o.client.Logger().Warn("failed to remove outbox segment", "error", err)
+			return
+		}
+
+		o.segments = o.segments[1:]
+	}
+}
+
+// load reads all existing segment files in order, rebuilding the index of
+// pending entries.
+func (o *Outbox) load() error {
+	files, err := ioutil.ReadDir(o.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read outbox directory: %w", err)
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() ||
+			!strings.HasPrefix(name, outboxSegmentPrefix) ||
+			!strings.HasSuffix(name, outboxSegmentSuffix) {
+			continue
+		}
+
+		seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, outboxSegmentPrefix), outboxSegmentSuffix))
+		if err != nil {
+			continue
+		}
+
+		o.segments = append(o.segments, &outboxSegment{
+			seq:  seq,
+			path: filepath.Join(o.dir, name),
+		})
+	}
+
+	sort.Slice(o.segments, func(i, j int) bool {
+		return o.segments[i].seq < o.segments[j].seq
+	})
+
+	for _, segment := range o.segments {
+		if err := o.loadSegment(segment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadSegment applies the records from a single segment file to the index
+// of pending entries. Malformed records (e.g., a partially written record
+// from a process that exited unexpectedly) are skipped.
+func (o *Outbox) loadSegment(segment *outboxSegment) error {
+	f, err := os.Open(segment.path)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox segment: %w", err)
+	}
+
+	defer func() {
+		if err := f.Close(); err != nil {
+			o.client.Logger().Warn("failed to close outbox segment", "error", err)
+		}
+	}()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record outboxRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				o.client.Logger().Warn("skipping malformed outbox record", "path", segment.path, "error", err)
+			} else {
+				o.apply(segment, record)
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+
+		if readErr != nil {
+			return fmt.Errorf("failed to read outbox segment: %w", readErr)
+		}
+	}
+}
+
+// apply updates the index of pending entries using a record loaded from the
+// given segment.
+func (o *Outbox) apply(segment *outboxSegment, record outboxRecord) {
+	if record.ID >= o.nextID {
+		o.nextID = record.ID + 1
+	}
+
+	switch record.Op {
+	case outboxOpAdd:
+		o.entries[record.ID] = &outboxEntry{
+			OutboxEntry: OutboxEntry{
+				ID:             record.ID,
+				WebhookURL:     record.WebhookURL,
+				Payload:        []byte(record.Payload),
+				Created:        time.Unix(0, record.Created),
+				IdempotencyKey: record.Key,
+				skipValidation: record.SkipValidation,
+			},
+			segment: segment,
+		}
+		segment.live++
+
+	case outboxOpDone, outboxOpDrop:
+		if entry, ok := o.entries[record.ID]; ok {
+			delete(o.entries, record.ID)
+			entry.segment.live--
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxReplayAfterRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	requireNoError(t, err)
+	defer os.RemoveAll(dir)
+
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+
+	var failedRequests int
+	failing := NewTeamsClient().SetHTTPClient(
+		newScriptedTestClient(&failedRequests, scriptedResponse{status: http.StatusServiceUnavailable}),
+	)
+
+	outbox, err := OpenOutbox(dir, failing, OutboxConfig{})
+	requireNoError(t, err)
+
+	for _, text := range []string{"first", "second"} {
+		msgCard := NewMessageCard()
+		msgCard.Text = text
+		assert.Error(t, outbox.Send(context.Background(), webhookURL, &msgCard))
+	}
+
+	// Permanent failures are not kept for a later replay.
+	var rejectedRequests int
+	rejecting := NewTeamsClient().SetHTTPClient(
+		newScriptedTestClient(&rejectedRequests, scriptedResponse{status: http.StatusBadRequest}),
+	)
+	outbox.client = rejecting
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "rejected"
+	assert.Error(t, outbox.Send(context.Background(), webhookURL, &msgCard))
+
+	assert.Len(t, outbox.Pending(), 2)
+	requireNoError(t, outbox.Close())
+
+	// Simulate a partially written record left behind by a crash.
+	segments, err := filepath.Glob(filepath.Join(dir, outboxSegmentPrefix+"*"))
+	requireNoError(t, err)
+	if !assert.Len(t, segments, 1) {
+		t.FailNow()
+	}
+	f, err := os.OpenFile(segments[0], os.O_APPEND|os.O_WRONLY, 0600)
+	requireNoError(t, err)
+	_, err = f.WriteString(`{"op":"add","id":99,"webh`)
+	requireNoError(t, err)
+	requireNoError(t, f.Close())
+
+	var requests int
+	working := NewTeamsClient().SetHTTPClient(
+		newScriptedTestClient(&requests, scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText}),
+	)
+
+	outbox, err = OpenOutbox(dir, working, OutboxConfig{})
+	requireNoError(t, err)
+
+	pending := outbox.Pending()
+	if assert.Len(t, pending, 2) {
+		assert.Equal(t, webhookURL, pending[0].WebhookURL)
+		assert.Contains(t, string(pending[0].Payload), "first")
+		assert.Contains(t, string(pending[1].Payload), "second")
+	}
+
+	delivered, err := outbox.Replay(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, delivered)
+	assert.Equal(t, 2, requests)
+	assert.Empty(t, outbox.Pending())
+	requireNoError(t, outbox.Close())
+
+	// Settled segments are removed, leaving only the current segment.
+	segments, err = filepath.Glob(filepath.Join(dir, outboxSegmentPrefix+"*"))
+	requireNoError(t, err)
+	assert.Len(t, segments, 1)
+
+	outbox, err = OpenOutbox(dir, working, OutboxConfig{})
+	requireNoError(t, err)
+	assert.Empty(t, outbox.Pending())
+	requireNoError(t, outbox.Close())
+}
+
+func TestOutboxExpireAndPurge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	requireNoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var requests int
+	failing := NewTeamsClient().SetHTTPClient(
+		newScriptedTestClient(&requests, scriptedResponse{status: http.StatusInternalServerError}),
+	)
+
+	outbox, err := OpenOutbox(dir, failing, OutboxConfig{MaxAge: time.Hour})
+	requireNoError(t, err)
+	defer outbox.Close()
+
+	for _, webhookURL := range []string{
+		"https://outlook.office.com/webhook/aaa",
+		"https://outlook.office.com/webhook/bbb",
+		"https://outlook.office.com/webhook/ccc",
+	} {
+		msgCard := NewMessageCard()
+		msgCard.Text = "Hello World"
+		assert.Error(t, outbox.Send(context.Background(), webhookURL, &msgCard))
+	}
+
+	// Age the first entry beyond the maximum age.
+	outbox.mu.Lock()
+	outbox.entries[1].Created = time.Now().Add(-2 * time.Hour)
+	outbox.mu.Unlock()
+
+	expired, err := outbox.Expire()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	purged, err := outbox.Purge(func(entry OutboxEntry) bool {
+		return entry.WebhookURL == "https://outlook.office.com/webhook/bbb"
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	pending := outbox.Pending()
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "https://outlook.office.com/webhook/ccc", pending[0].WebhookURL)
+	}
+
+	purged, err = outbox.Purge(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.Empty(t, outbox.Pending())
+}
+
+func TestOutboxRejectsOversizedMessage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	requireNoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var requests int
+	client := NewTeamsClient(
+		WithHTTPClient(newScriptedTestClient(&requests, scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText})),
+		WithMaxPayloadSize(512),
+	)
+
+	outbox, err := OpenOutbox(dir, client, OutboxConfig{})
+	requireNoError(t, err)
+	defer outbox.Close()
+
+	msgCard := NewMessageCard()
+	msgCard.Text = strings.Repeat("x", 1024)
+
+	err = outbox.Send(context.Background(), "https://outlook.office.com/webhook/xxx", &msgCard)
+	assert.True(t, errors.Is(err, ErrPayloadTooLarge))
+	assert.False(t, IsRetryableError(err))
+	assert.Empty(t, outbox.Pending())
+	assert.Zero(t, requests)
+}
+
+// requireNoError stops the test if the given error is not nil.
+func requireNoError(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Microsoft Teams throttles incoming webhooks to a handful of requests per
+// second per connector. These defaults aim to stay within those limits.
+//
+// https://docs.microsoft.com/en-us/microsoftteams/platform/webhooks-and-connectors/how-to/connectors-using#rate-limiting-for-connectors
+const (
+	// DefaultRateLimit is the default number of requests per second allowed
+	// per webhook URL.
+	DefaultRateLimit float64 = 4
+
+	// DefaultRateLimitBurst is the default number of requests which may be
+	// submitted at once per webhook URL before rate limiting applies.
+	DefaultRateLimitBurst int = 4
+)
+
+// rateLimitMinFraction limits how far the request rate for a webhook URL is
+// reduced in response to throttling by the remote endpoint (relative to the
+// configured rate).
+const rateLimitMinFraction float64 = 16
+
+// rateLimitRecoveryFraction controls how quickly the request rate for a
+// webhook URL recovers after throttling; each successful submission restores
+// this fraction of the configured rate.
+const rateLimitRecoveryFraction float64 = 10
+
+// rateLimitSweepInterval is the minimum period of time between checks for
+// idle token buckets. A bucket is idle once it is full at the configured
+// rate, making it equivalent to a new bucket, and is removed so that a
+// long-running RateLimiter does not retain state for every webhook URL it
+// has seen.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimiter is a token bucket rate limiter keyed by webhook URL. Each
+// webhook URL is allowed the configured number of requests per second with
+// bursts of up to the configured size.
+//
+// When the remote endpoint indicates that requests are throttled, the rate
+// for the affected webhook URL is reduced and requests are paused for the
+// duration requested by the endpoint. The rate recovers gradually as
+// messages are successfully submitted.
+//
+// A RateLimiter is safe for concurrent use and may be shared by multiple
+// clients.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   int
+	clock   Clock
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+// tokenBucket tracks the available tokens for a single webhook URL as of the
+// last update.
+type tokenBucket struct {
+	tokens float64
+	rate   float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter which allows the given number of
+// requests per second for each webhook URL with bursts of up to the given
+// size. Default values are used for a non-positive rate or burst.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if rate <= 0 {
+		rate = DefaultRateLimit
+	}
+
+	if burst <= 0 {
+		burst = DefaultRateLimitBurst
+	}
+
+	return &RateLimiter{
+		rate:    rate,
+		burst:   burst,
+		clock:   systemClock{},
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// SetClock accepts a custom Clock which replaces the default time-based
+// clock used to track and wait for available tokens.
+func (l *RateLimiter) SetClock(clock Clock) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.clock = clock
+
+	return l
+}
+
+// Rate returns the current number of requests per second allowed for the
+// given webhook URL. This is lower than the configured rate if the remote
+// endpoint has recently throttled requests.
+func (l *RateLimiter) Rate(webhookURL string) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[webhookURL]; ok {
+		return b.rate
+	}
+
+	return l.rate
+}
+
+// Wait blocks until a request to the given webhook URL is allowed or the
+// given context is cancelled, whichever occurs first. The context error is
+// returned if the context is cancelled before the request is allowed.
+func (l *RateLimiter) Wait(ctx context.Context, webhookURL string) error {
+	l.mu.Lock()
+
+	clock := l.clock
+	now := clock.Now()
+	b := l.bucket(webhookURL, now)
+
+	// Tokens are reserved at the earliest time a request is allowed; this
+	// may be in the future if previous requests have already reserved the
+	// available tokens or if the endpoint has requested a pause.
+	start := now
+	if b.last.After(start) {
+		start = b.last
+	}
+	b.advance(start, l.burst)
+
+	if b.tokens < 1 {
+		start = start.Add(time.Duration((1 - b.tokens) / b.rate * float64(time.Second)))
+		b.tokens = 1
+		b.last = start
+	}
+	b.tokens--
+
+	l.mu.Unlock()
+
+	wait := start.Sub(now)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	if err := clock.Sleep(ctx, wait); err != nil {
+		// Return the reserved token so that it is available to others.
+		l.mu.Lock()
+		b.tokens++
+		l.mu.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// observe adapts the rate for the given webhook URL based on the result of
+// a message submission. The rate is reduced if the endpoint throttled the
+// request and gradually restored otherwise.
+func (l *RateLimiter) observe(webhookURL string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	b := l.bucket(webhookURL, now)
+
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		if !b.last.After(now) {
+			b.advance(now, l.burst)
+		}
+
+		b.rate /= 2
+		if minRate := l.rate / rateLimitMinFraction; b.rate < minRate {
+			b.rate = minRate
+		}
+
+		pause := retryAfter(err)
+		if pause <= 0 {
+			pause = time.Duration(float64(time.Second) / b.rate)
+		}
+
+		b.tokens = 0
+		if until := now.Add(pause); until.After(b.last) {
+			b.last = until
+		}
+
+	case err == nil && b.rate < l.rate:
+		if !b.last.After(now) {
+			b.advance(now, l.burst)
+		}
+
+		b.rate += l.rate / rateLimitRecoveryFraction
+		if b.rate > l.rate {
+			b.rate = l.rate
+		}
+	}
+}
+
+// bucket returns the token bucket for the given webhook URL, creating a full
+// bucket if one does not already exist. The caller must hold the lock.
+func (l *RateLimiter) bucket(webhookURL string, now time.Time) *tokenBucket {
+	b, ok := l.buckets[webhookURL]
+	if !ok {
+		l.sweep(now)
+
+		b = &tokenBucket{
+			tokens: float64(l.burst),
+			rate:   l.rate,
+			last:   now,
+		}
+		l.buckets[webhookURL] = b
+	}
+
+	return b
+}
+
+// sweep removes idle token buckets. The caller must hold the lock.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < rateLimitSweepInterval {
+		return
+	}
+	l.swept = now
+
+	for webhookURL, b := range l.buckets {
+		if b.rate < l.rate || b.last.After(now) {
+			continue
+		}
+
+		if b.tokens+now.Sub(b.last).Seconds()*b.rate >= float64(l.burst) {
+			delete(l.buckets, webhookURL)
+		}
+	}
+}
+
+// advance adds the tokens accumulated between the last update and the given
+// time, never exceeding the given burst size.
+func (b *tokenBucket) advance(t time.Time, burst int) {
+	if elapsed := t.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+	}
+
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+
+	b.last = t
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+	otherWebhookURL := "https://outlook.office.com/webhook/yyy"
+
+	clock := &fakeClock{now: time.Now()}
+	limiter := NewRateLimiter(1, 2).SetClock(clock)
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, limiter.Wait(context.Background(), webhookURL))
+	}
+
+	// The burst is allowed immediately, later requests wait for a token.
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, clock.sleeps)
+
+	// Each webhook URL has its own bucket.
+	assert.NoError(t, limiter.Wait(context.Background(), otherWebhookURL))
+	assert.Len(t, clock.sleeps, 2)
+}
+
+func TestRateLimiterAdaptsToThrottling(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+
+	clock := &fakeClock{now: time.Now()}
+	limiter := NewRateLimiter(4, 4).SetClock(clock)
+
+	throttled := &SendError{
+		StatusCode: http.StatusTooManyRequests,
+		RetryAfter: 5 * time.Second,
+	}
+	limiter.observe(webhookURL, throttled)
+
+	assert.Equal(t, float64(2), limiter.Rate(webhookURL))
+
+	// Requests are paused for the duration requested by the endpoint.
+	assert.NoError(t, limiter.Wait(context.Background(), webhookURL))
+	assert.Equal(t, []time.Duration{5*time.Second + 500*time.Millisecond}, clock.sleeps)
+
+	// Throttling reported via response text is also recognized.
+	textErr := &SendError{
+		StatusCode:   http.StatusOK,
+		ResponseText: "Webhook message delivery failed with error: Microsoft Teams endpoint returned HTTP error 429",
+		Err:          ErrInvalidWebhookURLResponseText,
+	}
+	limiter.observe(webhookURL, textErr)
+	assert.Equal(t, float64(1), limiter.Rate(webhookURL))
+
+	// Successful submissions gradually restore the configured rate.
+	for i := 0; i < 50; i++ {
+		limiter.observe(webhookURL, nil)
+	}
+	assert.Equal(t, float64(4), limiter.Rate(webhookURL))
+}
+
+func TestRateLimiterEvictsIdleBuckets(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+	throttledWebhookURL := "https://outlook.office.com/webhook/yyy"
+
+	clock := &fakeClock{now: time.Now()}
+	limiter := NewRateLimiter(1, 2).SetClock(clock)
+
+	assert.NoError(t, limiter.Wait(context.Background(), webhookURL))
+	limiter.observe(throttledWebhookURL, &SendError{StatusCode: http.StatusTooManyRequests})
+
+	// Full buckets at the configured rate are removed; the throttled bucket
+	// is kept.
+	clock.now = clock.now.Add(rateLimitSweepInterval)
+	assert.NoError(t, limiter.Wait(context.Background(), "https://outlook.office.com/webhook/zzz"))
+	assert.Len(t, limiter.buckets, 2)
+	assert.Equal(t, 0.5, limiter.Rate(throttledWebhookURL))
+	assert.NotContains(t, limiter.buckets, webhookURL)
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+	limiter := NewRateLimiter(0.001, 1)
+
+	assert.NoError(t, limiter.Wait(context.Background(), webhookURL))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Error(t, limiter.Wait(ctx, webhookURL))
+}
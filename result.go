@@ -0,0 +1,246 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TraceTimings are the timings of a single HTTP request collected using the
+// net/http/httptrace package. Timings are zero for phases which did not
+// occur (e.g., DNS lookup and connection setup when an existing connection
+// was reused).
+type TraceTimings struct {
+	// DNS is the time taken to resolve the webhook URL host.
+	DNS time.Duration
+
+	// Connect is the time taken to establish the TCP connection.
+	Connect time.Duration
+
+	// TLSHandshake is the time taken by the TLS handshake.
+	TLSHandshake time.Duration
+
+	// TimeToFirstByte is the time from the start of the request until the
+	// first byte of the response was received.
+	TimeToFirstByte time.Duration
+
+	// ReusedConnection indicates whether an existing connection was used.
+	ReusedConnection bool
+}
+
+// AttemptResult describes a single message submission attempt.
+type AttemptResult struct {
+	// Attempt is the number of the attempt, starting at 1.
+	Attempt int
+
+	// Duration is the time taken by the request to the remote endpoint.
+	Duration time.Duration
+
+	// StatusCode is the HTTP status code returned by the remote endpoint, or
+	// zero if a response was not received.
+	StatusCode int
+
+	// ResponseText is the response body returned by the remote endpoint.
+	ResponseText string
+
+	// Err is the error for a failed attempt.
+	Err error
+
+	// Trace provides the timings of the HTTP request.
+	Trace TraceTimings
+}
+
+// SendResult describes the outcome of a message submission made using
+// TeamsClient.SendWithResult.
+type SendResult struct {
+	// Attempts is the number of requests made to the remote endpoint.
+	Attempts int
+
+	// Duration is the total time taken, including delays between attempts.
+	Duration time.Duration
+
+	// StatusCode is the HTTP status code returned for the final attempt, or
+	// zero if a response was not received.
+	StatusCode int
+
+	// ResponseText is the response body returned for the final attempt.
+	ResponseText string
+
+	// AttemptResults describe each attempt in the order made.
+	AttemptResults []AttemptResult
+}
+
+// SendWithResult submits a given message to a Microsoft Teams channel using
+// the provided webhook URL in the same manner as SendWithContext, returning
+// details of each attempt made along with any error. A SendResult is
+// returned even if the message submission fails.
+func (c *TeamsClient) SendWithResult(ctx context.Context, webhookURL string, message Message) (*SendResult, error) {
+	collector := &resultCollector{}
+
+	start := c.Clock().Now()
+	err := c.SendWithContext(context.WithValue(ctx, resultCollectorKey{}, collector), webhookURL, message)
+
+	result := collector.result()
+	result.Duration = c.Clock().Now().Sub(start)
+
+	return result, err
+}
+
+// resultCollectorKey is the context key for a *resultCollector.
+type resultCollectorKey struct{}
+
+// resultCollector gathers the attempts made for a single SendWithResult
+// call.
+type resultCollector struct {
+	mu       sync.Mutex
+	attempts []AttemptResult
+}
+
+// resultCollectorFromContext returns the *resultCollector for the given
+// context, or nil if results are not being collected.
+func resultCollectorFromContext(ctx context.Context) *resultCollector {
+	collector, _ := ctx.Value(resultCollectorKey{}).(*resultCollector)
+
+	return collector
+}
+
+// add records an attempt. The status code and response text are taken from
+// the error for a failed attempt.
+func (rc *resultCollector) add(attempt int, trace *attemptTrace, statusCode int, responseText string, err error) {
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		statusCode = sendErr.StatusCode
+		responseText = sendErr.ResponseText
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.attempts = append(rc.attempts, AttemptResult{
+		Attempt:      attempt,
+		Duration:     trace.duration(),
+		StatusCode:   statusCode,
+		ResponseText: responseText,
+		Err:          err,
+		Trace:        trace.timings(),
+	})
+}
+
+// result returns a SendResult for the recorded attempts.
+func (rc *resultCollector) result() *SendResult {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	result := SendResult{
+		Attempts:       len(rc.attempts),
+		AttemptResults: rc.attempts,
+	}
+
+	if n := len(rc.attempts); n > 0 {
+		result.StatusCode = rc.attempts[n-1].StatusCode
+		result.ResponseText = rc.attempts[n-1].ResponseText
+	}
+
+	return &result
+}
+
+// attemptTrace collects timings for a single HTTP request. Trace callbacks
+// may be called concurrently.
+type attemptTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	end          time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	trace        TraceTimings
+}
+
+// newAttemptTrace creates an attemptTrace for a request starting now.
+func newAttemptTrace() *attemptTrace {
+	return &attemptTrace{start: time.Now()}
+}
+
+// clientTrace returns the httptrace.ClientTrace which collects timings.
+func (t *attemptTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.DNS = time.Since(t.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.Connect = time.Since(t.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.TLSHandshake = time.Since(t.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.ReusedConnection = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.trace.TimeToFirstByte = time.Since(t.start)
+		},
+	}
+}
+
+// finish marks the end of the request.
+func (t *attemptTrace) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.end = time.Now()
+}
+
+// duration returns the time taken by the request.
+func (t *attemptTrace) duration() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.end.IsZero() {
+		return time.Since(t.start)
+	}
+
+	return t.end.Sub(t.start)
+}
+
+// timings returns the collected timings.
+func (t *attemptTrace) timings() TraceTimings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.trace
+}
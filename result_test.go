@@ -0,0 +1,74 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeamsClientSendWithResult(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("try again later"))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(ExpectedWebhookURLResponseText))
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Now()}
+	client := NewTeamsClient().
+		SkipWebhookURLValidationOnSend(true).
+		SetRetryPolicy(NewConstantBackoff(2, time.Second)).
+		SetClock(clock)
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	result, err := client.SendWithResult(context.Background(), server.URL, &msgCard)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, result.Attempts)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, ExpectedWebhookURLResponseText, result.ResponseText)
+	assert.Equal(t, time.Second, result.Duration)
+
+	if assert.Len(t, result.AttemptResults, 2) {
+		first := result.AttemptResults[0]
+		assert.Equal(t, 1, first.Attempt)
+		assert.Equal(t, http.StatusServiceUnavailable, first.StatusCode)
+		assert.Equal(t, "try again later", first.ResponseText)
+		assert.ErrorIs(t, first.Err, ErrServerError)
+		assert.False(t, first.Trace.ReusedConnection)
+		assert.Greater(t, int64(first.Trace.TimeToFirstByte), int64(0))
+		assert.GreaterOrEqual(t, int64(first.Duration), int64(first.Trace.TimeToFirstByte))
+
+		second := result.AttemptResults[1]
+		assert.Equal(t, 2, second.Attempt)
+		assert.Equal(t, http.StatusOK, second.StatusCode)
+		assert.NoError(t, second.Err)
+		assert.Greater(t, int64(second.Trace.TimeToFirstByte), int64(0))
+	}
+
+	// Messages rejected before submission report no attempts.
+	result, err = client.SendWithResult(context.Background(), server.URL, &MessageCard{})
+	assert.Error(t, err)
+	assert.Equal(t, 0, result.Attempts)
+	assert.Equal(t, 0, result.StatusCode)
+}
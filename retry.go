@@ -0,0 +1,369 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryAttempt describes a failed message submission attempt. It is provided
+// to a RetryPolicy in order to determine whether (and when) another attempt
+// should be made.
+type RetryAttempt struct {
+	// Attempt is the number of the failed attempt, starting at 1.
+	Attempt int
+
+	// Err is the error returned from the failed attempt.
+	Err error
+
+	// PreviousDelay is the delay applied before the failed attempt. This is
+	// zero for the initial attempt.
+	PreviousDelay time.Duration
+
+	// Elapsed is the time elapsed since the initial attempt was made.
+	Elapsed time.Duration
+}
+
+// RetryPolicy determines whether a failed message submission attempt should
+// be retried and how long to wait before doing so.
+//
+// A RetryPolicy is only consulted for errors classified as retryable (see
+// IsRetryableError). If the endpoint provides a Retry-After value which is
+// longer than the delay returned by the policy, the Retry-After value is
+// used instead.
+type RetryPolicy interface {
+	// NextDelay returns the delay to apply before the next attempt and
+	// whether another attempt should be made.
+	NextDelay(attempt RetryAttempt) (time.Duration, bool)
+}
+
+// Clock provides the current time and the ability to wait. A custom Clock
+// may be provided to a TeamsClient in order to control retry timing (e.g.,
+// for testing purposes).
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// Sleep waits for the given duration or until the given context is
+	// cancelled, whichever occurs first. The context error is returned if
+	// the context is cancelled before the duration elapses.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// systemClock is the default Clock implementation backed by the time
+// package.
+type systemClock struct{}
+
+// Now returns the current local time.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep waits for the given duration or until the given context is
+// cancelled, whichever occurs first.
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ConstantBackoff is a RetryPolicy which applies the same delay between each
+// attempt.
+type ConstantBackoff struct {
+	// MaxRetries is the number of retries allowed after the initial attempt.
+	MaxRetries int
+
+	// Delay is the delay applied between attempts.
+	Delay time.Duration
+}
+
+// NewConstantBackoff creates a RetryPolicy which retries up to the specified
+// number of times, waiting the given delay between attempts.
+func NewConstantBackoff(retries int, delay time.Duration) *ConstantBackoff {
+	return &ConstantBackoff{
+		MaxRetries: retries,
+		Delay:      delay,
+	}
+}
+
+// NextDelay returns the configured delay until the maximum number of retries
+// is reached.
+func (b ConstantBackoff) NextDelay(attempt RetryAttempt) (time.Duration, bool) {
+	if attempt.Attempt > b.MaxRetries {
+		return 0, false
+	}
+
+	return b.Delay, true
+}
+
+// maxBackoffDelay is the upper limit for delays calculated by the backoff
+// policies, well within the range of time.Duration.
+const maxBackoffDelay time.Duration = 1 << 62
+
+// ExponentialBackoff is a RetryPolicy which increases the delay between each
+// attempt by a fixed multiplier, optionally applying random jitter.
+type ExponentialBackoff struct {
+	// MaxRetries is the number of retries allowed after the initial attempt.
+	MaxRetries int
+
+	// InitialDelay is the delay applied after the initial attempt.
+	InitialDelay time.Duration
+
+	// MaxDelay is the upper limit for any single delay. A zero value
+	// indicates that no limit is applied.
+	MaxDelay time.Duration
+
+	// Multiplier is the factor applied to the delay for each subsequent
+	// attempt. A value less than 1 is treated as the default of 2.
+	Multiplier float64
+
+	// Jitter is the fraction (0 to 1) of each delay which is randomized. A
+	// value of 0.5 results in delays between 50% and 100% of the computed
+	// delay.
+	Jitter float64
+}
+
+// NewExponentialBackoff creates a RetryPolicy which retries up to the
+// specified number of times, doubling the delay after each attempt starting
+// at the initial delay and never exceeding the maximum delay.
+func NewExponentialBackoff(retries int, initialDelay time.Duration, maxDelay time.Duration) *ExponentialBackoff {
+	return &ExponentialBackoff{
+		MaxRetries:   retries,
+		InitialDelay: initialDelay,
+		MaxDelay:     maxDelay,
+		Multiplier:   2,
+	}
+}
+
+// NextDelay returns an exponentially increasing delay until the maximum
+// number of retries is reached.
+func (b ExponentialBackoff) NextDelay(attempt RetryAttempt) (time.Duration, bool) {
+	if attempt.Attempt > b.MaxRetries {
+		return 0, false
+	}
+
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	limit := maxBackoffDelay
+	if b.MaxDelay > 0 && b.MaxDelay < limit {
+		limit = b.MaxDelay
+	}
+
+	// The limit is applied before conversion to a Duration to guard against
+	// overflow for large attempt numbers.
+	delay := float64(b.InitialDelay) * math.Pow(multiplier, float64(attempt.Attempt-1))
+	switch {
+	case math.IsNaN(delay) || delay < 0:
+		delay = 0
+	case delay > float64(limit):
+		delay = float64(limit)
+	}
+
+	jitter := b.Jitter
+	switch {
+	case jitter < 0:
+		jitter = 0
+	case jitter > 1:
+		jitter = 1
+	}
+
+	if jitter > 0 && delay > 0 {
+		delay -= jitter * delay * rand.Float64() // nolint:gosec
+	}
+
+	return time.Duration(delay), true
+}
+
+// DecorrelatedJitterBackoff is a RetryPolicy which applies the "decorrelated
+// jitter" algorithm; each delay is a random value between the base delay and
+// three times the previous delay, never exceeding the maximum delay.
+//
+// See https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+type DecorrelatedJitterBackoff struct {
+	// MaxRetries is the number of retries allowed after the initial attempt.
+	MaxRetries int
+
+	// BaseDelay is the minimum delay applied between attempts.
+	BaseDelay time.Duration
+
+	// MaxDelay is the upper limit for any single delay. A zero value
+	// indicates that no limit is applied.
+	MaxDelay time.Duration
+}
+
+// NewDecorrelatedJitterBackoff creates a RetryPolicy which retries up to the
+// specified number of times using randomized delays between the base and
+// maximum delay.
+func NewDecorrelatedJitterBackoff(retries int, baseDelay time.Duration, maxDelay time.Duration) *DecorrelatedJitterBackoff {
+	return &DecorrelatedJitterBackoff{
+		MaxRetries: retries,
+		BaseDelay:  baseDelay,
+		MaxDelay:   maxDelay,
+	}
+}
+
+// NextDelay returns a randomized delay until the maximum number of retries
+// is reached.
+func (b DecorrelatedJitterBackoff) NextDelay(attempt RetryAttempt) (time.Duration, bool) {
+	if attempt.Attempt > b.MaxRetries {
+		return 0, false
+	}
+
+	previous := attempt.PreviousDelay
+	switch {
+	case previous < b.BaseDelay:
+		previous = b.BaseDelay
+	case previous > maxBackoffDelay/3:
+		previous = maxBackoffDelay / 3
+	}
+
+	delay := b.BaseDelay
+	if upper := previous * 3; upper > b.BaseDelay {
+		delay += time.Duration(rand.Int63n(int64(upper - b.BaseDelay))) // nolint:gosec
+	}
+
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+
+	return delay, true
+}
+
+// permanentError wraps an error which is not expected to succeed if the
+// message submission is retried (e.g., a validation failure).
+type permanentError struct {
+	err error
+}
+
+// Error returns the message of the wrapped error.
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// IsRetryableError indicates whether a message submission which failed with
+// the given error may succeed if retried.
+//
+// Validation failures, an open circuit, submissions aborted by an
+// Interceptor and HTTP status codes indicating a client error (e.g., 400 Bad
+// Request due to a malformed message) are not retryable. Network errors,
+// timeouts, throttling (429) and server errors (5xx) are retryable. Failures
+// reported by Microsoft Teams in the response text provided along with a 200
+// status code are classified using the reported status code.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pErr *permanentError
+	if errors.As(err, &pErr) {
+		return false
+	}
+
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrSendAborted) {
+		return false
+	}
+
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		if statusCode := sendErr.EffectiveStatusCode(); statusCode >= http.StatusMultipleChoices {
+			return isRetryableStatusCode(statusCode)
+		}
+	}
+
+	return true
+}
+
+// isRetryableStatusCode indicates whether a request which failed with the
+// given HTTP status code may succeed if retried.
+func isRetryableStatusCode(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests:
+		return true
+
+	case http.StatusNotImplemented,
+		http.StatusHTTPVersionNotSupported:
+		return false
+
+	default:
+		return statusCode >= http.StatusInternalServerError
+	}
+}
+
+// retryAfter returns the Retry-After duration provided by the remote
+// endpoint for the given error, or zero if one was not provided.
+func retryAfter(err error) time.Duration {
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		return sendErr.RetryAfter
+	}
+
+	return 0
+}
+
+// parseRetryAfter parses the Retry-After header value of the given response
+// if the status code indicates throttling (429) or an unavailable service
+// (503). The header value may be provided as a number of seconds or as an
+// HTTP date relative to the given time. Zero is returned if a value is not
+// provided or is invalid.
+func parseRetryAfter(response *http.Response, now time.Time) time.Duration {
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+	default:
+		return 0
+	}
+
+	value := strings.TrimSpace(response.Header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		switch {
+		case seconds < 0:
+			return 0
+		case int64(seconds) > int64(maxBackoffDelay/time.Second):
+			return maxBackoffDelay
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
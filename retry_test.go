@@ -0,0 +1,261 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a Clock which records requested delays instead of waiting.
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+
+	return ctx.Err()
+}
+
+// scriptedResponse is a response returned by a test client for a single
+// request.
+type scriptedResponse struct {
+	status int
+	body   string
+	header http.Header
+}
+
+// newScriptedTestClient returns a http.Client which returns the given
+// responses in order, repeating the last response once exhausted. The number
+// of requests made is recorded in the given counter.
+func newScriptedTestClient(requests *int, responses ...scriptedResponse) *http.Client {
+	return NewTestClient(func(req *http.Request) (*http.Response, error) {
+		res := responses[len(responses)-1]
+		if *requests < len(responses) {
+			res = responses[*requests]
+		}
+		*requests++
+
+		header := res.header
+		if header == nil {
+			header = make(http.Header)
+		}
+
+		return &http.Response{
+			StatusCode: res.status,
+			Status:     http.StatusText(res.status),
+			Body:       ioutil.NopCloser(bytes.NewBufferString(res.body)),
+			Header:     header,
+		}, nil
+	})
+}
+
+func TestSendWithRetryPolicy(t *testing.T) {
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+	success := scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText}
+	now := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		responses    []scriptedResponse
+		wantRequests int
+		wantSleeps   []time.Duration
+		wantErr      bool
+	}{
+		{
+			name:   "bad request is not retried",
+			policy: NewConstantBackoff(3, time.Second),
+			responses: []scriptedResponse{
+				{status: http.StatusBadRequest, body: "Summary or Text is required."},
+			},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		{
+			name:   "server errors use exponential delays",
+			policy: NewExponentialBackoff(3, time.Second, 3*time.Second),
+			responses: []scriptedResponse{
+				{status: http.StatusInternalServerError},
+				{status: http.StatusBadGateway},
+				{status: http.StatusServiceUnavailable},
+				success,
+			},
+			wantRequests: 4,
+			wantSleeps:   []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+		},
+		{
+			name:   "retry-after is honored for throttled requests",
+			policy: NewConstantBackoff(1, time.Second),
+			responses: []scriptedResponse{
+				{
+					status: http.StatusTooManyRequests,
+					header: http.Header{"Retry-After": []string{"7"}},
+				},
+				success,
+			},
+			wantRequests: 2,
+			wantSleeps:   []time.Duration{7 * time.Second},
+		},
+		{
+			name:   "retry-after date is relative to the clock",
+			policy: NewConstantBackoff(1, time.Second),
+			responses: []scriptedResponse{
+				{
+					status: http.StatusServiceUnavailable,
+					header: http.Header{"Retry-After": []string{now.Add(20 * time.Second).Format(http.TimeFormat)}},
+				},
+				success,
+			},
+			wantRequests: 2,
+			wantSleeps:   []time.Duration{20 * time.Second},
+		},
+		{
+			name:   "retries exhausted",
+			policy: NewConstantBackoff(2, time.Second),
+			responses: []scriptedResponse{
+				{status: http.StatusInternalServerError},
+			},
+			wantRequests: 3,
+			wantSleeps:   []time.Duration{time.Second, time.Second},
+			wantErr:      true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			var requests int
+			clock := &fakeClock{now: now}
+
+			msgCard := NewMessageCard()
+			msgCard.Text = "Hello World"
+
+			c := NewTeamsClient().
+				SetHTTPClient(newScriptedTestClient(&requests, test.responses...)).
+				SetRetryPolicy(test.policy).
+				SetClock(clock)
+
+			err := c.SendWithContext(context.Background(), webhookURL, &msgCard)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, test.wantRequests, requests)
+			assert.Equal(t, test.wantSleeps, clock.sleeps)
+		})
+	}
+}
+
+func TestBackoffDelayLimits(t *testing.T) {
+	exponential := ExponentialBackoff{MaxRetries: 1000, InitialDelay: time.Second, Multiplier: 2}
+
+	delay, ok := exponential.NextDelay(RetryAttempt{Attempt: 100})
+	assert.True(t, ok)
+	assert.Equal(t, maxBackoffDelay, delay)
+
+	exponential.MaxDelay = time.Minute
+	delay, _ = exponential.NextDelay(RetryAttempt{Attempt: 1000})
+	assert.Equal(t, time.Minute, delay)
+
+	exponential.Jitter = 0.5
+	delay, _ = exponential.NextDelay(RetryAttempt{Attempt: 1000})
+	assert.True(t, delay >= 30*time.Second && delay <= time.Minute)
+
+	decorrelated := DecorrelatedJitterBackoff{MaxRetries: 1, BaseDelay: time.Second}
+	delay, ok = decorrelated.NextDelay(RetryAttempt{Attempt: 1, PreviousDelay: maxBackoffDelay})
+	assert.True(t, ok)
+	assert.True(t, delay >= time.Second && delay <= maxBackoffDelay)
+
+	// Delays which would extend past the context deadline are not applied.
+	var requests int
+	clock := &fakeClock{now: time.Now()}
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Hour))
+	defer cancel()
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	for _, delay := range []time.Duration{2 * time.Hour, 30 * time.Minute} {
+		requests = 0
+		c := NewTeamsClient(
+			WithHTTPClient(newScriptedTestClient(&requests,
+				scriptedResponse{status: http.StatusInternalServerError},
+				scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText},
+			)),
+			WithRetryPolicy(NewConstantBackoff(1, delay)),
+			WithClock(clock),
+		)
+
+		err := c.SendWithContext(ctx, "https://outlook.office.com/webhook/xxx", &msgCard)
+		if delay > time.Hour {
+			assert.Error(t, err)
+			assert.Equal(t, 1, requests)
+			assert.Empty(t, clock.sleeps)
+			continue
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, requests)
+		assert.Equal(t, []time.Duration{delay}, clock.sleeps)
+	}
+}
+
+func TestSendWithRetryCancelledDuringDelay(t *testing.T) {
+	var requests int
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	c := NewTeamsClient().SetHTTPClient(
+		newScriptedTestClient(&requests, scriptedResponse{status: http.StatusInternalServerError}),
+	)
+
+	// Cancelling the context must interrupt the delay between attempts.
+	start := time.Now()
+	err := c.SendWithRetryPolicy(ctx, "https://outlook.office.com/webhook/xxx", &msgCard, NewConstantBackoff(3, time.Minute))
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, requests)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestIsRetryableError(t *testing.T) {
+	assert.False(t, IsRetryableError(nil))
+	assert.False(t, IsRetryableError(&permanentError{errors.New("invalid")}))
+	assert.False(t, IsRetryableError(&SendError{StatusCode: http.StatusBadRequest}))
+	assert.False(t, IsRetryableError(&SendError{StatusCode: http.StatusNotImplemented}))
+	assert.True(t, IsRetryableError(&SendError{StatusCode: http.StatusTooManyRequests}))
+	assert.True(t, IsRetryableError(&SendError{StatusCode: http.StatusGatewayTimeout}))
+	assert.True(t, IsRetryableError(errors.New("connection reset by peer")))
+}
@@ -9,6 +9,7 @@
 package goteamsnotify
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -16,11 +17,13 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
+	"net/http/httptrace"
 	"os"
-	"regexp"
 	"strings"
 	"time"
+
+	"github.com/rmasci/go-teams-notify/v2/botapi"
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
 )
 
 // logger is a package logger that can be enabled from client code to allow
@@ -48,6 +51,11 @@ const (
 	// webhook URL prefix patterns.
 	DefaultWebhookURLValidationPattern = `^https:\/\/(?:.*\.webhook|outlook)\.office(?:365)?\.com`
 
+	// DefaultWorkflowsWebhookURLValidationPattern is a minimal regex for
+	// matching known valid Power Automate Workflows webhook URL prefix
+	// patterns.
+	DefaultWorkflowsWebhookURLValidationPattern = `^https:\/\/[^\/]+\.(?:logic\.azure|environment\.api\.powerplatform)\.com(?::443)?\/`
+
 	// Note: The regex allows for capital letters in the GUID patterns. This is
 	// allowed based on light testing which shows that mixed case works and the
 	// assumption that since Teams and Office 365 are Microsoft products case
@@ -76,6 +84,12 @@ const DefaultWebhookSendTimeout = 5 * time.Second
 // "upstream" or parent project.
 const DefaultUserAgent string = "go-teams-notify/2.2"
 
+// DefaultMaxPayloadSize is the recommended maximum size in bytes of a
+// prepared message payload. Microsoft Teams rejects payloads larger than
+// (approximately) 28 KB. The size is not checked unless a maximum is set
+// (e.g., using WithMaxPayloadSize(DefaultMaxPayloadSize)).
+const DefaultMaxPayloadSize int = 28 * 1024
+
 // ErrWebhookURLUnexpected is returned when a provided webhook URL does
 // not match a set of confirmed webhook URL patterns.
 var ErrWebhookURLUnexpected = errors.New("webhook URL does not match one of expected patterns")
@@ -112,6 +126,21 @@ type MessageSender interface {
 	HTTPClient() *http.Client
 	UserAgent() string
 	ValidateWebhook(webhookURL string) error
+	RateLimiter() *RateLimiter
+	CircuitBreaker() *CircuitBreaker
+	MaxPayloadSize() int
+	Clock() Clock
+	Logger() Logger
+	MetricsRecorder() MetricsRecorder
+	DryRun() *DryRun
+
+	// loggedWebhookURL returns the given webhook URL as it should appear in
+	// log entries.
+	loggedWebhookURL(webhookURL string) string
+
+	// attemptInterceptors returns the interceptors applied to each message
+	// submission attempt.
+	attemptInterceptors() []Interceptor
 
 	// A private method to prevent client code from implementing the interface
 	// so that any future changes to it will not violate backwards
@@ -119,42 +148,44 @@ type MessageSender interface {
 	private()
 }
 
-// messagePreparer is a message type that supports marshaling its fields
-// as preparation for delivery to an endpoint.
-type messagePreparer interface {
-	Prepare(recreate bool) error
-}
-
-// messageValidator is a message type that provides validation of its format.
-type messageValidator interface {
-	Validate() error
-}
-
-// teamsMessage is the interface shared by all supported message formats for
-// submission to a Microsoft Teams channel.
-type teamsMessage interface {
-	messagePreparer
-	messageValidator
-
-	Payload() io.Reader
-}
-
 // teamsClient is the legacy client used for submitting messages to a
 // Microsoft Teams channel.
 type teamsClient struct {
 	HttpClient                   *http.Client
 	userAgent                    string
-	webhookURLValidationPatterns []string
+	webhookURLValidationPatterns []webhookPattern
 	skipWebhookURLValidation     bool
 }
 
 // TeamsClient provides functionality for submitting messages to a Microsoft
 // Teams channel.
+//
+// A TeamsClient is safe for concurrent use provided its configuration is not
+// changed while messages are being submitted. Configure the client by
+// passing Option values to NewTeamsClient and use the With method to derive
+// a client with different configuration; the deprecated methods which change
+// the configuration of a client (e.g., SetUserAgent) must not be called on a
+// client which is in use. The http.Client is shared with derived clients
+// rather than copied; see HTTPClient.
 type TeamsClient struct {
 	HttpClient                   *http.Client
 	userAgent                    string
-	webhookURLValidationPatterns []string
+	webhookURLValidationPatterns []webhookPattern
 	skipWebhookURLValidation     bool
+	retryPolicy                  RetryPolicy
+	clock                        Clock
+	rateLimiter                  *RateLimiter
+	circuitBreaker               *CircuitBreaker
+	maxPayloadSize               int
+	splitOversizedMessages       bool
+	fanOutConcurrency            int
+	interceptors                 []Interceptor
+	logger                       Logger
+	logWebhookURLs               bool
+	metricsRecorder              MetricsRecorder
+	dryRun                       *DryRun
+	sendTimeout                  time.Duration
+	webhookValidator             *WebhookValidator
 }
 
 func init() {
@@ -162,10 +193,13 @@ func init() {
 	// requests it
 	logger = log.New(os.Stderr, "[goteamsnotify] ", 0)
 	logger.SetOutput(ioutil.Discard)
+
+	packageLogger = NewStdLogger(logger, LogLevelDebug)
 }
 
 // EnableLogging enables logging output from this package. Output is muted by
-// default unless explicitly requested (by calling this function).
+// default unless explicitly requested (by calling this function). Clients
+// configured with a custom Logger are not affected.
 func EnableLogging() {
 	logger.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	logger.SetOutput(os.Stderr)
@@ -192,9 +226,10 @@ func NewClient() API {
 	return &Client
 }
 
-// NewTeamsClient constructs a minimal client for submitting messages to a
-// Microsoft Teams channel.
-func NewTeamsClient() *TeamsClient {
+// NewTeamsClient constructs a client for submitting messages to a Microsoft
+// Teams channel. The given options are applied in order; a minimal client is
+// returned if no options are given.
+func NewTeamsClient(opts ...Option) *TeamsClient {
 	Client := TeamsClient{
 		HttpClient: &http.Client{
 			// We're using a context instead of setting this directly
@@ -202,6 +237,11 @@ func NewTeamsClient() *TeamsClient {
 		},
 		skipWebhookURLValidation: false,
 	}
+
+	for _, opt := range opts {
+		opt(&Client)
+	}
+
 	return &Client
 }
 
@@ -215,6 +255,9 @@ func (c *TeamsClient) private() {}
 
 // SetHTTPClient accepts a custom http.Client value which replaces the
 // existing default http.Client.
+//
+// Deprecated: use the WithHTTPClient option with NewTeamsClient or
+// TeamsClient.With instead.
 func (c *TeamsClient) SetHTTPClient(httpClient *http.Client) *TeamsClient {
 	c.HttpClient = httpClient
 
@@ -223,12 +266,151 @@ func (c *TeamsClient) SetHTTPClient(httpClient *http.Client) *TeamsClient {
 
 // SetUserAgent accepts a custom user agent string. This custom user agent is
 // used when submitting messages to Microsoft Teams.
+//
+// Deprecated: use the WithUserAgent option with NewTeamsClient or
+// TeamsClient.With instead.
 func (c *TeamsClient) SetUserAgent(userAgent string) *TeamsClient {
 	c.userAgent = userAgent
 
 	return c
 }
 
+// SetRetryPolicy accepts a RetryPolicy which is applied when submitting
+// messages.
+//
+// Deprecated: use the WithRetryPolicy option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetRetryPolicy(policy RetryPolicy) *TeamsClient {
+	c.retryPolicy = policy
+
+	return c
+}
+
+// SetClock accepts a custom Clock which replaces the default time-based
+// clock used to apply delays between retry attempts and to measure the time
+// taken by send attempts.
+//
+// Deprecated: use the WithClock option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetClock(clock Clock) *TeamsClient {
+	c.clock = clock
+
+	return c
+}
+
+// RetryPolicy returns the configured RetryPolicy for the client or nil if
+// one has not been set.
+func (c *TeamsClient) RetryPolicy() RetryPolicy {
+	return c.retryPolicy
+}
+
+// Clock returns the default time-based clock used by the legacy client.
+//
+// Deprecated: use TeamsClient.Clock() method instead.
+func (c *teamsClient) Clock() Clock {
+	return systemClock{}
+}
+
+// Clock returns the configured Clock for the client. If a custom Clock is
+// not set the default time-based clock is returned.
+func (c *TeamsClient) Clock() Clock {
+	if c.clock == nil {
+		return systemClock{}
+	}
+
+	return c.clock
+}
+
+// SetRateLimiter accepts a RateLimiter which is applied to each message
+// submission attempt.
+//
+// Deprecated: use the WithRateLimiter option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetRateLimiter(limiter *RateLimiter) *TeamsClient {
+	c.rateLimiter = limiter
+
+	return c
+}
+
+// RateLimiter returns the configured RateLimiter for the client or nil if
+// one has not been set.
+//
+// Deprecated: use TeamsClient.RateLimiter() method instead.
+func (c *teamsClient) RateLimiter() *RateLimiter {
+	return nil
+}
+
+// RateLimiter returns the configured RateLimiter for the client or nil if
+// one has not been set.
+func (c *TeamsClient) RateLimiter() *RateLimiter {
+	return c.rateLimiter
+}
+
+// SetCircuitBreaker accepts a CircuitBreaker which is applied to each
+// message submission attempt.
+//
+// Deprecated: use the WithCircuitBreaker option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetCircuitBreaker(breaker *CircuitBreaker) *TeamsClient {
+	c.circuitBreaker = breaker
+
+	return c
+}
+
+// CircuitBreaker returns the configured CircuitBreaker for the client or nil
+// if one has not been set.
+//
+// Deprecated: use TeamsClient.CircuitBreaker() method instead.
+func (c *teamsClient) CircuitBreaker() *CircuitBreaker {
+	return nil
+}
+
+// CircuitBreaker returns the configured CircuitBreaker for the client or nil
+// if one has not been set.
+func (c *TeamsClient) CircuitBreaker() *CircuitBreaker {
+	return c.circuitBreaker
+}
+
+// SetMaxPayloadSize accepts the maximum size in bytes of a prepared message
+// payload.
+//
+// Deprecated: use the WithMaxPayloadSize option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetMaxPayloadSize(size int) *TeamsClient {
+	c.maxPayloadSize = size
+
+	return c
+}
+
+// SetSplitOversizedMessages allows the caller to optionally enable splitting
+// of oversized messages.
+//
+// Deprecated: use the WithSplitOversizedMessages option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetSplitOversizedMessages(split bool) *TeamsClient {
+	c.splitOversizedMessages = split
+
+	return c
+}
+
+// MaxPayloadSize returns the maximum size in bytes of a prepared message
+// payload, or zero if the size is not checked.
+//
+// Deprecated: use TeamsClient.MaxPayloadSize() method instead.
+func (c *teamsClient) MaxPayloadSize() int {
+	return 0
+}
+
+// MaxPayloadSize returns the maximum size in bytes of a prepared message
+// payload, or zero if the size is not checked.
+func (c *TeamsClient) MaxPayloadSize() int {
+	if c.maxPayloadSize < 0 {
+		return 0
+	}
+
+	return c.maxPayloadSize
+}
+
 // UserAgent returns the configured user agent string for the client. If a
 // custom value is not set the default package user agent is returned.
 //
@@ -258,14 +440,20 @@ func (c *TeamsClient) UserAgent() string {
 //
 // Deprecated: use TeamsClient.AddWebhookURLValidationPatterns() method instead.
 func (c *teamsClient) AddWebhookURLValidationPatterns(patterns ...string) API {
-	c.webhookURLValidationPatterns = append(c.webhookURLValidationPatterns, patterns...)
+	c.webhookURLValidationPatterns = append(c.webhookURLValidationPatterns, compileWebhookPatterns(patterns)...)
 	return c
 }
 
 // AddWebhookURLValidationPatterns collects given patterns for validation of
 // the webhook URL.
+//
+// Deprecated: use the WithWebhookURLValidationPatterns option with NewTeamsClient or
+// TeamsClient.With instead.
 func (c *TeamsClient) AddWebhookURLValidationPatterns(patterns ...string) *TeamsClient {
-	c.webhookURLValidationPatterns = append(c.webhookURLValidationPatterns, patterns...)
+	c.webhookURLValidationPatterns = append(
+		c.webhookURLValidationPatterns[:len(c.webhookURLValidationPatterns):len(c.webhookURLValidationPatterns)],
+		compileWebhookPatterns(patterns)...,
+	)
 	return c
 }
 
@@ -278,7 +466,9 @@ func (c *teamsClient) HTTPClient() *http.Client {
 }
 
 // HTTPClient returns the internal pointer to an http.Client. This can be used
-// to further modify specific http.Client field values.
+// to further modify specific http.Client field values. The http.Client is
+// shared with clients derived using With, so such changes affect all of them
+// and must not be made while messages are being submitted.
 func (c *TeamsClient) HTTPClient() *http.Client {
 	return c.HttpClient
 }
@@ -296,13 +486,14 @@ func (c *teamsClient) Send(webhookURL string, webhookMessage MessageCard) error
 }
 
 // Send is a wrapper function around the SendWithContext method in order to
-// provide backwards compatibility.
-func (c *TeamsClient) Send(webhookURL string, message teamsMessage) error {
+// provide backwards compatibility. The submission times out after the
+// configured send timeout; see WithSendTimeout.
+func (c *TeamsClient) Send(webhookURL string, message Message) error {
 	// Create context that can be used to emulate existing timeout behavior.
-	ctx, cancel := context.WithTimeout(context.Background(), DefaultWebhookSendTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), c.SendTimeout())
 	defer cancel()
 
-	return sendWithContext(ctx, c, webhookURL, message)
+	return c.SendWithContext(ctx, webhookURL, message)
 }
 
 // SendWithContext submits a given message to a Microsoft Teams channel using
@@ -317,8 +508,120 @@ func (c *teamsClient) SendWithContext(ctx context.Context, webhookURL string, we
 // SendWithContext submits a given message to a Microsoft Teams channel using
 // the provided webhook URL. The http client request honors the cancellation
 // or timeout of the provided context.
-func (c *TeamsClient) SendWithContext(ctx context.Context, webhookURL string, message teamsMessage) error {
-	return sendWithContext(ctx, c, webhookURL, message)
+//
+// If a RetryPolicy has been set for the client, failed attempts are retried
+// as directed by the policy. If splitting of oversized messages is enabled,
+// each part of an oversized message is submitted in turn.
+func (c *TeamsClient) SendWithContext(ctx context.Context, webhookURL string, message Message) error {
+	if card, ok := message.(*messagecard.MessageCard); ok && c.splitOversizedMessages {
+		return c.sendSplit(ctx, webhookURL, card)
+	}
+
+	return c.send(ctx, webhookURL, message)
+}
+
+// send submits a given message, applying the RetryPolicy for the client if
+// one has been set.
+func (c *TeamsClient) send(ctx context.Context, webhookURL string, message Message) error {
+	if c.retryPolicy == nil {
+		return sendWithContext(ctx, c, webhookURL, message)
+	}
+
+	return sendWithRetry(ctx, c, webhookURL, message, c.retryPolicy, c.Clock())
+}
+
+// sendSplit submits a given message card, first splitting it into numbered
+// cards if the payload submitted to the webhook URL would exceed the maximum
+// payload size. Parts are
+// submitted in order and submission stops at the first part which fails.
+func (c *TeamsClient) sendSplit(ctx context.Context, webhookURL string, card *messagecard.MessageCard) error {
+	limit := c.MaxPayloadSize()
+	if limit <= 0 {
+		return c.send(ctx, webhookURL, card)
+	}
+
+	if err := card.Validate(); err != nil {
+		return &permanentError{fmt.Errorf(
+			"failed to validate message: %w",
+			err,
+		)}
+	}
+
+	size, err := submittedSize(webhookURL, card)
+	if err != nil {
+		return &permanentError{fmt.Errorf(
+			"failed to prepare message: %w",
+			err,
+		)}
+	}
+
+	if size <= limit {
+		return c.send(ctx, webhookURL, card)
+	}
+
+	// Messages submitted to Workflows webhook URLs are converted to an
+	// Adaptive Card envelope whose size varies with the content, so the
+	// split size is reduced until each converted part is within the limit.
+	splitSize := limit
+	var parts []*messagecard.MessageCard
+	for {
+		parts, err = card.Split(splitSize)
+		if err != nil {
+			return &permanentError{fmt.Errorf(
+				"failed to split message: %v: %w",
+				err,
+				ErrPayloadTooLarge,
+			)}
+		}
+
+		largest := 0
+		for _, part := range parts {
+			partSize, err := submittedSize(webhookURL, part)
+			if err != nil {
+				return &permanentError{fmt.Errorf(
+					"failed to prepare split message: %w",
+					err,
+				)}
+			}
+
+			if partSize > largest {
+				largest = partSize
+			}
+		}
+
+		if largest <= limit {
+			break
+		}
+
+		splitSize -= largest - limit
+		if splitSize <= 0 {
+			return &permanentError{fmt.Errorf(
+				"failed to split message within limit of %d bytes: %w",
+				limit,
+				ErrPayloadTooLarge,
+			)}
+		}
+	}
+
+	c.Logger().Info(
+		"splitting oversized message",
+		"webhook", c.loggedWebhookURL(webhookURL),
+		"bytes", size,
+		"parts", len(parts),
+	)
+
+	for i, part := range parts {
+		if err := c.send(ctx, webhookURL, part); err != nil {
+			return fmt.Errorf(
+				"failed to submit part %d of %d: %w",
+				i+1,
+				len(parts),
+				err,
+			)
+		}
+	}
+
+	return nil
 }
 
 // SendWithRetry provides message retry support when submitting messages to a
@@ -327,14 +630,26 @@ func (c *TeamsClient) SendWithContext(ctx context.Context, webhookURL string, me
 //
 // Deprecated: use TeamsClient.SendWithRetry() method instead.
 func (c *teamsClient) SendWithRetry(ctx context.Context, webhookURL string, webhookMessage MessageCard, retries int, retriesDelay int) error {
-	return sendWithRetry(ctx, c, webhookURL, &webhookMessage, retries, retriesDelay)
+	policy := NewConstantBackoff(retries, time.Duration(retriesDelay)*time.Second)
+
+	return sendWithRetry(ctx, c, webhookURL, &webhookMessage, policy, systemClock{})
 }
 
 // SendWithRetry provides message retry support when submitting messages to a
 // Microsoft Teams channel. The caller is responsible for providing the
-// desired context timeout, the number of retries and retries delay.
-func (c *TeamsClient) SendWithRetry(ctx context.Context, webhookURL string, message teamsMessage, retries int, retriesDelay int) error {
-	return sendWithRetry(ctx, c, webhookURL, message, retries, retriesDelay)
+// desired context timeout, the number of retries and retries delay (in
+// seconds). Any RetryPolicy set for the client is not used.
+func (c *TeamsClient) SendWithRetry(ctx context.Context, webhookURL string, message Message, retries int, retriesDelay int) error {
+	policy := NewConstantBackoff(retries, time.Duration(retriesDelay)*time.Second)
+
+	return sendWithRetry(ctx, c, webhookURL, message, policy, c.Clock())
+}
+
+// SendWithRetryPolicy provides message retry support when submitting
+// messages to a Microsoft Teams channel using the given RetryPolicy. The
+// caller is responsible for providing the desired context timeout.
+func (c *TeamsClient) SendWithRetryPolicy(ctx context.Context, webhookURL string, message Message, policy RetryPolicy) error {
+	return sendWithRetry(ctx, c, webhookURL, message, policy, c.Clock())
 }
 
 // SkipWebhookURLValidationOnSend allows the caller to optionally disable
@@ -348,6 +663,9 @@ func (c *teamsClient) SkipWebhookURLValidationOnSend(skip bool) API {
 
 // SkipWebhookURLValidationOnSend allows the caller to optionally disable
 // webhook URL validation.
+//
+// Deprecated: use the WithSkipWebhookURLValidation option with NewTeamsClient or
+// TeamsClient.With instead.
 func (c *TeamsClient) SkipWebhookURLValidationOnSend(skip bool) *TeamsClient {
 	c.skipWebhookURLValidation = skip
 	return c
@@ -356,10 +674,18 @@ func (c *TeamsClient) SkipWebhookURLValidationOnSend(skip bool) *TeamsClient {
 // prepareRequest is a helper function that prepares a http.Request (including
 // all desired headers) in order to submit a given prepared message to an
 // endpoint.
-func prepareRequest(ctx context.Context, userAgent string, webhookURL string, preparedMessage io.Reader) (*http.Request, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, preparedMessage)
+//
+// The request body is replayable; GetBody is set so that retries, 307/308
+// redirects and HTTP/2 connection replays submit the full prepared message.
+func prepareRequest(ctx context.Context, userAgent string, webhookURL string, preparedMessage []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(preparedMessage))
 	if err != nil {
-		return nil, err
+		return nil, redactURLError(err, webhookURL)
+	}
+
+	req.ContentLength = int64(len(preparedMessage))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(preparedMessage)), nil
 	}
 
 	req.Header.Add("Content-Type", "application/json;charset=utf-8")
@@ -368,15 +694,69 @@ func prepareRequest(ctx context.Context, userAgent string, webhookURL string, pr
 	return req, nil
 }
 
+// submittedSize returns the size in bytes of the payload submitted to the
+// given webhook URL for the message, including conversion to the envelope
+// expected by Workflows webhook URLs.
+func submittedSize(webhookURL string, message Message) (int, error) {
+	snapshot, err := snapshotMessage(message)
+	if err != nil {
+		return 0, err
+	}
+
+	payload, err := preparedPayload(snapshot)
+	if err != nil {
+		return 0, err
+	}
+
+	if webhookHostType(webhookURL) == WebhookHostWorkflows {
+		payload, err = workflowsPayload(payload)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(payload), nil
+}
+
+// snapshotMessage returns an immutable prepared copy of the given message
+// for message types which provide one; the message itself is not modified
+// so that it may be submitted from multiple goroutines. Other message types
+// are prepared and returned as-is.
+func snapshotMessage(message Message) (Message, error) {
+	switch m := message.(type) {
+	case *messagecard.MessageCard:
+		return m.Snapshot()
+	case *botapi.Message:
+		return m.Snapshot()
+	case *MessageCard:
+		return m.snapshot()
+	}
+
+	if err := message.Prepare(false); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// preparedPayload is a helper function that returns the prepared payload for
+// a given message. A new reader is requested from the message so that the
+// returned bytes reflect the full prepared payload regardless of how often
+// the message has been submitted.
+func preparedPayload(message Message) ([]byte, error) {
+	return ioutil.ReadAll(message.Payload())
+}
+
 // processResponse is a helper function responsible for validating a response
-// from an endpoint after submitting a message.
-func processResponse(response *http.Response) (string, error) {
+// from an endpoint after submitting a message. Power Automate Workflows
+// webhook URLs indicate success using any 2xx status code (usually 202
+// Accepted with an empty body) while other endpoints are expected to return
+// ExpectedWebhookURLResponseText.
+func processResponse(response *http.Response, hostType WebhookHostType, clock Clock) (string, error) {
 	// Get the response body, then convert to string for use with extended
 	// error messages
 	responseData, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		logger.Println(err)
-
 		return "", err
 	}
 	responseString := string(responseData)
@@ -388,12 +768,18 @@ func processResponse(response *http.Response) (string, error) {
 	// "Summary or Text is required." as a text string. We include that
 	// response text in the error message that we return to the caller.
 	case response.StatusCode >= 299:
-		err = fmt.Errorf("error on notification: %v, %q", response.Status, responseString)
-
-		logger.Println(err)
+		err = &SendError{
+			StatusCode:   response.StatusCode,
+			Status:       response.Status,
+			ResponseText: responseString,
+			RetryAfter:   parseRetryAfter(response, clock.Now()),
+		}
 
 		return "", err
 
+	case hostType == WebhookHostWorkflows:
+		return responseString, nil
+
 	// Microsoft Teams developers have indicated that a 200 status code is
 	// insufficient to confirm that a message was successfully submitted.
 	// Instead, clients should ensure that a specific response string was also
@@ -403,14 +789,12 @@ func processResponse(response *http.Response) (string, error) {
 	//
 	// See atc0005/go-teams-notify#59 for more information.
 	case responseString != strings.TrimSpace(ExpectedWebhookURLResponseText):
-		err = fmt.Errorf(
-			"got %q, expected %q: %w",
-			responseString,
-			ExpectedWebhookURLResponseText,
-			ErrInvalidWebhookURLResponseText,
-		)
-
-		logger.Println(err)
+		err = &SendError{
+			StatusCode:   response.StatusCode,
+			Status:       response.Status,
+			ResponseText: responseString,
+			Err:          ErrInvalidWebhookURLResponseText,
+		}
 
 		return "", err
 
@@ -420,38 +804,45 @@ func processResponse(response *http.Response) (string, error) {
 }
 
 // validateWebhook applies webhook URL validation unless explicitly disabled.
-func validateWebhook(webhookURL string, skipWebhookValidation bool, patterns []string) error {
+// The given validator is used if not nil, otherwise the given patterns (or
+// default patterns) are used.
+func validateWebhook(log Logger, webhookURL string, skipWebhookValidation bool, validator *WebhookValidator, patterns []webhookPattern) error {
 	if skipWebhookValidation || webhookURL == DisableWebhookURLValidation {
-		logger.Printf("validateWebhook: Webhook URL will not be validated: %#v\n", webhookURL)
+		log.Debug("webhook URL validation disabled")
 
 		return nil
 	}
 
-	u, err := url.Parse(webhookURL)
+	if validator != nil {
+		return validator.Validate(webhookURL)
+	}
+
+	u, err := ParseWebhookURL(webhookURL)
 	if err != nil {
-		return fmt.Errorf("unable to parse webhook URL %q: %w", webhookURL, err)
+		return fmt.Errorf("%w; %v", ErrWebhookURLUnexpected, err)
 	}
 
 	if len(patterns) == 0 {
-		patterns = []string{DefaultWebhookURLValidationPattern}
+		patterns = defaultWebhookPatterns
 	}
 
 	// Indicate passing validation if at least one pattern matches.
+	expected := make([]string, 0, len(patterns))
 	for _, pat := range patterns {
-		matched, err := regexp.MatchString(pat, webhookURL)
-		if err != nil {
-			return err
+		if pat.err != nil {
+			return pat.err
 		}
-		if matched {
+		if pat.re.MatchString(webhookURL) {
 			return nil
 		}
+		expected = append(expected, pat.pattern)
 	}
 
 	return fmt.Errorf(
 		"%w; got: %q, patterns: %s",
 		ErrWebhookURLUnexpected,
-		u.String(),
-		strings.Join(patterns, ","),
+		u.Redacted(),
+		strings.Join(expected, ","),
 	)
 }
 
@@ -459,139 +850,364 @@ func validateWebhook(webhookURL string, skipWebhookValidation bool, patterns []s
 //
 // Deprecated: use TeamsClient.ValidateWebhook() method instead.
 func (c *teamsClient) ValidateWebhook(webhookURL string) error {
-	return validateWebhook(webhookURL, c.skipWebhookURLValidation, c.webhookURLValidationPatterns)
+	return validateWebhook(c.Logger(), webhookURL, c.skipWebhookURLValidation, nil, c.webhookURLValidationPatterns)
 }
 
 // ValidateWebhook applies webhook URL validation unless explicitly disabled.
 func (c *TeamsClient) ValidateWebhook(webhookURL string) error {
-	return validateWebhook(webhookURL, c.skipWebhookURLValidation, c.webhookURLValidationPatterns)
+	return validateWebhook(c.Logger(), webhookURL, c.skipWebhookURLValidation, c.webhookValidator, c.webhookURLValidationPatterns)
 }
 
 // sendWithContext submits a given message to a Microsoft Teams channel using
 // the provided webhook URL and client. The http client request honors the
 // cancellation or timeout of the provided context.
-func sendWithContext(ctx context.Context, client MessageSender, webhookURL string, message teamsMessage) error {
-	logger.Printf("sendWithContext: Webhook message received: %#v\n", message)
+func sendWithContext(ctx context.Context, client MessageSender, webhookURL string, message Message) error {
+	clock := client.Clock()
+	start := clock.Now()
+	err := sendAttempt(ctx, client, webhookURL, message, 1)
+	recordSend(client, webhookURL, 1, clock.Now().Sub(start), err)
+
+	return err
+}
+
+// sendAttempt makes the given attempt to submit a given message to a
+// Microsoft Teams channel using the provided webhook URL and client. The
+// http client request honors the cancellation or timeout of the provided
+// context.
+func sendAttempt(ctx context.Context, client MessageSender, webhookURL string, message Message, attempt int) (result error) {
+	log := client.Logger()
+	loggedURL := client.loggedWebhookURL(webhookURL)
+	clock := client.Clock()
+
+	var payloadSize int
+	var start time.Time
+	if recorder := client.MetricsRecorder(); recorder != nil {
+		defer func() {
+			var duration time.Duration
+			if !start.IsZero() {
+				duration = clock.Now().Sub(start)
+			}
+
+			recorder.RecordAttempt(AttemptMetrics{
+				Host:        webhookHost(webhookURL),
+				Outcome:     ClassifyOutcome(result),
+				Attempt:     attempt,
+				Duration:    duration,
+				PayloadSize: payloadSize,
+			})
+		}()
+	}
+
+	log.Debug("submitting message", "webhook", loggedURL, "type", fmt.Sprintf("%T", message))
 
 	if err := client.ValidateWebhook(webhookURL); err != nil {
-		return fmt.Errorf(
+		return &permanentError{fmt.Errorf(
 			"failed to validate webhook URL: %w",
 			err,
-		)
+		)}
 	}
 
 	if err := message.Validate(); err != nil {
-		return fmt.Errorf(
+		return &permanentError{fmt.Errorf(
 			"failed to validate message: %w",
 			err,
-		)
+		)}
 	}
 
-	if err := message.Prepare(false); err != nil {
-		return fmt.Errorf(
+	snapshot, err := snapshotMessage(message)
+	if err != nil {
+		return &permanentError{fmt.Errorf(
 			"failed to prepare message: %w",
 			err,
-		)
+		)}
 	}
 
-	req, err := prepareRequest(ctx, client.UserAgent(), webhookURL, message.Payload())
+	payload, err := preparedPayload(snapshot)
 	if err != nil {
-		return fmt.Errorf(
+		return &permanentError{fmt.Errorf(
+			"failed to retrieve prepared message: %w",
+			err,
+		)}
+	}
+
+	hostType := webhookHostType(webhookURL)
+	if hostType == WebhookHostWorkflows {
+		payload, err = workflowsPayload(payload)
+		if err != nil {
+			return &permanentError{fmt.Errorf(
+				"failed to wrap message for Workflows webhook URL: %w",
+				err,
+			)}
+		}
+	}
+
+	if limit := client.MaxPayloadSize(); limit > 0 && len(payload) > limit {
+		return &permanentError{fmt.Errorf(
+			"prepared message is %d bytes, exceeding limit of %d bytes: %w",
+			len(payload),
+			limit,
+			ErrPayloadTooLarge,
+		)}
+	}
+
+	payloadSize = len(payload)
+	log.Debug("prepared message", "webhook", loggedURL, "bytes", payloadSize)
+
+	// The breaker distinguishes cancellation by the caller from an attempt
+	// timing out using the context given by the caller.
+	parent := ctx
+
+	options := sendOptionsFromContext(ctx)
+	if options != nil && options.attemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.attemptTimeout)
+		defer cancel()
+	}
+
+	req, err := prepareRequest(ctx, client.UserAgent(), webhookURL, payload)
+	if err != nil {
+		return &permanentError{fmt.Errorf(
 			"failed to prepare request: %w",
 			err,
-		)
+		)}
+	}
+
+	var idempotencyKey string
+	if options != nil {
+		for key, values := range options.header {
+			req.Header[key] = append([]string(nil), values...)
+		}
+		idempotencyKey = options.idempotencyKey
+	}
+
+	if breaker := client.CircuitBreaker(); breaker != nil {
+		if err := breaker.allow(client, webhookURL); err != nil {
+			return fmt.Errorf(
+				"failed to submit message: %w",
+				err,
+			)
+		}
+
+		defer func() {
+			breaker.record(parent, client, webhookURL, result)
+		}()
+	}
+
+	limiter := client.RateLimiter()
+	if limiter != nil {
+		if err := limiter.Wait(ctx, webhookURL); err != nil {
+			return fmt.Errorf(
+				"failed to wait for rate limiter: %w",
+				err,
+			)
+		}
+	}
+
+	var statusCode int
+	var responseText string
+	if collector := resultCollectorFromContext(ctx); collector != nil {
+		trace := newAttemptTrace()
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+
+		defer func() {
+			trace.finish()
+			collector.add(attempt, trace, statusCode, responseText, result)
+		}()
 	}
 
-	// Submit message to endpoint.
-	res, err := client.HTTPClient().Do(req)
+	start = clock.Now()
+
+	// Submit message to endpoint via any registered interceptors.
+	submit := chainInterceptors(client.attemptInterceptors(), func(r *SendRequest) (*http.Response, error) {
+		if dryRun := client.DryRun(); dryRun != nil {
+			log.Debug("dry run: message not submitted", "webhook", loggedURL)
+
+			return dryRun.submit(r.HTTPRequest)
+		}
+
+		return client.HTTPClient().Do(r.HTTPRequest)
+	})
+
+	res, err := submit(&SendRequest{
+		WebhookURL:     webhookURL,
+		Message:        message,
+		Payload:        append([]byte(nil), payload...),
+		HTTPRequest:    req,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err == nil && res == nil {
+		err = fmt.Errorf("no response received: %w", ErrSendAborted)
+	}
 	if err != nil {
+		if res != nil {
+			_ = res.Body.Close()
+		}
+
 		return fmt.Errorf(
 			"failed to submit message: %w",
-			err,
+			&SendError{
+				Host:    webhookHost(webhookURL),
+				Attempt: attempt,
+				Elapsed: clock.Now().Sub(start),
+				Err:     redactURLError(err, webhookURL),
+			},
 		)
 	}
 
 	// Make sure that we close the response body once we're done with it
 	defer func() {
 		if err := res.Body.Close(); err != nil {
-			log.Printf("error closing response body: %v", err)
+			log.Warn("failed to close response body", "webhook", loggedURL, "error", err)
 		}
 	}()
 
-	responseText, err := processResponse(res)
+	statusCode = res.StatusCode
+
+	responseText, err = processResponse(res, hostType, clock)
+	if limiter != nil {
+		limiter.observe(webhookURL, err)
+	}
 	if err != nil {
+		var sendErr *SendError
+		if errors.As(err, &sendErr) {
+			sendErr.Host = webhookHost(webhookURL)
+			sendErr.Attempt = attempt
+			sendErr.Elapsed = clock.Now().Sub(start)
+		}
+
+		log.Warn(
+			"remote endpoint rejected message",
+			"webhook", loggedURL,
+			"status", res.StatusCode,
+			"error", err,
+		)
+
 		return fmt.Errorf(
 			"failed to process response: %w",
 			err,
 		)
 	}
 
-	logger.Printf("sendWithContext: Response string from Microsoft Teams API: %v\n", responseText)
+	log.Debug("message submitted", "webhook", loggedURL, "response", responseText)
 
 	return nil
 }
 
 // sendWithRetry provides message retry support when submitting messages to a
 // Microsoft Teams channel. The caller is responsible for providing the
-// desired context timeout, the number of retries and retries delay.
-func sendWithRetry(ctx context.Context, client MessageSender, webhookURL string, message teamsMessage, retries int, retriesDelay int) error {
-	var result error
+// desired context timeout and the retry policy to apply. Errors which are
+// not retryable (see IsRetryableError) are returned without further
+// attempts. Delays between attempts honor the cancellation or timeout of the
+// provided context.
+func sendWithRetry(ctx context.Context, client MessageSender, webhookURL string, message Message, policy RetryPolicy, clock Clock) (err error) {
+	if clock == nil {
+		clock = systemClock{}
+	}
 
-	// initial attempt + number of specified retries
-	attemptsAllowed := 1 + retries
+	log := client.Logger()
+	loggedURL := client.loggedWebhookURL(webhookURL)
 
-	// attempt to send message to Microsoft Teams, retry specified number of
-	// times before giving up
-	for attempt := 1; attempt <= attemptsAllowed; attempt++ {
+	start := clock.Now()
+
+	var attempt int
+	defer func() {
+		recordSend(client, webhookURL, attempt, clock.Now().Sub(start), err)
+	}()
+
+	var delay time.Duration
+
+	// attempt to send message to Microsoft Teams, retry as directed by the
+	// policy before giving up
+	for attempt = 1; ; attempt++ {
 		// the result from the last attempt is returned to the caller
-		result = sendWithContext(ctx, client, webhookURL, message)
+		result := sendAttempt(ctx, client, webhookURL, message, attempt)
+		if result == nil {
+			log.Info("message sent", "webhook", loggedURL, "attempts", attempt)
+
+			// No further retries needed
+			return nil
+		}
+
+		var sendErr *SendError
+		if errors.As(result, &sendErr) {
+			sendErr.Attempt = attempt
+			sendErr.Elapsed = clock.Now().Sub(start)
+		}
 
-		switch {
-		case result != nil:
+		log.Warn("attempt to send message failed", "webhook", loggedURL, "attempt", attempt, "error", result)
 
-			logger.Printf(
-				"sendWithRetry: Attempt %d of %d to send message failed: %v",
+		if ctx.Err() != nil {
+			errMsg := fmt.Errorf(
+				"sendWithRetry: context cancelled or expired: %v; "+
+					"aborting message submission after %d attempts: %w",
+				ctx.Err().Error(),
 				attempt,
-				attemptsAllowed,
 				result,
 			)
 
-			if ctx.Err() != nil {
-				errMsg := fmt.Errorf(
-					"sendWithRetry: context cancelled or expired: %v; "+
-						"aborting message submission after %d of %d attempts: %w",
-					ctx.Err().Error(),
-					attempt,
-					attemptsAllowed,
-					result,
-				)
+			log.Error("aborting message submission", "webhook", loggedURL, "attempts", attempt, "error", errMsg)
+
+			return errMsg
+		}
 
-				logger.Println(errMsg)
+		if !IsRetryableError(result) {
+			log.Error("error not retryable; aborting message submission", "webhook", loggedURL, "attempts", attempt)
 
-				return errMsg
-			}
+			return result
+		}
+
+		var retry bool
+		if policy != nil {
+			delay, retry = policy.NextDelay(RetryAttempt{
+				Attempt:       attempt,
+				Err:           result,
+				PreviousDelay: delay,
+				Elapsed:       clock.Now().Sub(start),
+			})
+		}
+
+		if !retry {
+			log.Error("retry policy exhausted; aborting message submission", "webhook", loggedURL, "attempts", attempt)
+
+			return result
+		}
 
-			ourRetryDelay := time.Duration(retriesDelay) * time.Second
+		// Honor a longer delay requested by the remote endpoint.
+		if ra := retryAfter(result); ra > delay {
+			log.Debug("applying Retry-After delay requested by endpoint", "webhook", loggedURL, "delay", ra)
+			delay = ra
+		}
 
-			logger.Printf(
-				"sendWithRetry: Context not cancelled yet, applying retry delay of %v",
-				ourRetryDelay,
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			errMsg := fmt.Errorf(
+				"sendWithRetry: retry delay of %v exceeds context deadline; "+
+					"aborting message submission after %d attempts: %w",
+				delay,
+				attempt,
+				result,
 			)
-			time.Sleep(ourRetryDelay)
 
-		default:
-			logger.Printf(
-				"sendWithRetry: successfully sent message after %d of %d attempts\n",
+			log.Error("aborting message submission", "webhook", loggedURL, "attempts", attempt, "error", errMsg)
+
+			return errMsg
+		}
+
+		log.Debug("applying retry delay", "webhook", loggedURL, "delay", delay)
+
+		if err := clock.Sleep(ctx, delay); err != nil {
+			errMsg := fmt.Errorf(
+				"sendWithRetry: context cancelled or expired: %v; "+
+					"aborting message submission after %d attempts: %w",
+				err.Error(),
 				attempt,
-				attemptsAllowed,
+				result,
 			)
 
-			// No further retries needed
-			return nil
+			log.Error("aborting message submission", "webhook", loggedURL, "attempts", attempt, "error", errMsg)
+
+			return errMsg
 		}
 	}
-
-	return result
 }
 
 // old deprecated helper functions --------------------------------------------------------------------------------------------------------------
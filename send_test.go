@@ -10,12 +10,17 @@ package goteamsnotify
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -299,6 +304,171 @@ func TestTeamsClientSend(t *testing.T) {
 	}
 }
 
+func TestTeamsClientSendWithRetryReplaysPayload(t *testing.T) {
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	var bodies []string
+
+	client := NewTestClient(func(req *http.Request) (*http.Response, error) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			t.Fatalf("failed to read request body: %v", err)
+		}
+		bodies = append(bodies, string(body))
+
+		// Fail the first attempt in order to force a retry.
+		status, resBody := http.StatusInternalServerError, "error"
+		if len(bodies) > 1 {
+			status, resBody = http.StatusOK, ExpectedWebhookURLResponseText
+		}
+
+		return &http.Response{
+			StatusCode: status,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(resBody)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	c := NewTeamsClient().SetHTTPClient(client)
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+
+	err := c.SendWithRetry(context.Background(), webhookURL, &msgCard, 1, 0)
+	assert.NoError(t, err)
+
+	// The same prepared message should be reusable for another endpoint.
+	err = c.SendWithContext(context.Background(), webhookURL, &msgCard)
+	assert.NoError(t, err)
+
+	if assert.Len(t, bodies, 3) {
+		assert.NotEmpty(t, bodies[0])
+		assert.Equal(t, bodies[0], bodies[1])
+		assert.Equal(t, bodies[0], bodies[2])
+	}
+}
+
+func TestPrepareRequestGetBody(t *testing.T) {
+	payload := []byte(`{"text":"Hello World"}`)
+
+	req, err := prepareRequest(context.Background(), DefaultUserAgent, "https://outlook.office.com/webhook/xxx", payload)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, int64(len(payload)), req.ContentLength)
+
+	for i := 0; i < 2; i++ {
+		body, err := req.GetBody()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		got, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, payload, got)
+	}
+}
+
+func TestTeamsClientMaxPayloadSize(t *testing.T) {
+	var requests int
+	client := NewTeamsClient(
+		WithHTTPClient(newScriptedTestClient(&requests, scriptedResponse{
+			status: http.StatusOK,
+			body:   ExpectedWebhookURLResponseText,
+		})),
+	)
+
+	card := messagecard.NewMessageCard()
+	card.Text = strings.Repeat("x", DefaultMaxPayloadSize)
+
+	// The size is not checked by default.
+	assert.Equal(t, 0, client.MaxPayloadSize())
+	assert.NoError(t, client.SendWithContext(context.Background(), "https://outlook.office.com/webhook/xxx", card))
+	assert.Equal(t, 1, requests)
+
+	limited := client.With(WithMaxPayloadSize(DefaultMaxPayloadSize))
+	err := limited.SendWithContext(context.Background(), "https://outlook.office.com/webhook/xxx", card)
+	assert.True(t, errors.Is(err, ErrPayloadTooLarge))
+	assert.False(t, IsRetryableError(err))
+	assert.Equal(t, 1, requests)
+}
+
+func TestTeamsClientSplitOversizedMessages(t *testing.T) {
+	const maxSize = 4096
+
+	var bodies [][]byte
+	httpClient := NewTestClient(func(req *http.Request) (*http.Response, error) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		bodies = append(bodies, body)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(ExpectedWebhookURLResponseText)),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	client := NewTeamsClient().
+		SetHTTPClient(httpClient).
+		SetMaxPayloadSize(maxSize).
+		SetSplitOversizedMessages(true)
+
+	var logLines []string
+	for i := 0; i < 300; i++ {
+		logLines = append(logLines, fmt.Sprintf("step %03d: compiling package <%d>", i, i))
+	}
+
+	card := messagecard.NewMessageCard()
+	card.Title = "Build log"
+	card.Text = "Output:\n```go\n" + strings.Join(logLines, "\n") + "\n```\nDone."
+
+	section := messagecard.NewSection()
+	section.Title = "Timings"
+	for i := 0; i < 200; i++ {
+		assert.NoError(t, section.AddFactFromKeyValue(fmt.Sprintf("Step %d", i), "1.5s"))
+	}
+	assert.NoError(t, card.AddSection(section))
+
+	err := client.SendWithContext(context.Background(), "https://outlook.office.com/webhook/xxx", card)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if !assert.Greater(t, len(bodies), 2) {
+		return
+	}
+
+	var gotLines []string
+	var gotFacts []messagecard.SectionFact
+	for i, body := range bodies {
+		assert.LessOrEqual(t, len(body), maxSize)
+
+		var part messagecard.MessageCard
+		requireNoError(t, json.Unmarshal(body, &part))
+
+		assert.Equal(t, fmt.Sprintf("Build log (%d/%d)", i+1, len(bodies)), part.Title)
+		assert.Equal(t, 0, strings.Count(part.Text, "```")%2, "unbalanced code fence in part %d", i+1)
+
+		for _, line := range strings.Split(part.Text, "\n") {
+			if line != "" && !strings.HasPrefix(line, "```") {
+				gotLines = append(gotLines, line)
+			}
+		}
+
+		for _, s := range part.Sections {
+			gotFacts = append(gotFacts, s.Facts...)
+		}
+	}
+
+	wantLines := append([]string{"Output:"}, logLines...)
+	wantLines = append(wantLines, "Done.")
+	assert.Equal(t, wantLines, gotLines)
+	assert.Equal(t, section.Facts, gotFacts)
+}
+
 // helper for testing --------------------------------------------------------------------------------------------------
 
 // RoundTripFunc .
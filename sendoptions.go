@@ -0,0 +1,137 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// SendOption overrides client settings for a single message submission made
+// using SendWithOptions.
+type SendOption func(o *sendOptions)
+
+// sendOptions are the settings applied by SendOption values.
+type sendOptions struct {
+	deadline       time.Time
+	attemptTimeout time.Duration
+	retryPolicy    RetryPolicy
+	retryPolicySet bool
+	header         http.Header
+	skipValidation bool
+	idempotencyKey string
+}
+
+// SendDeadline sets the time by which the message submission, including any
+// retries and delays between attempts, must complete. An earlier deadline
+// set on the provided context still applies.
+func SendDeadline(deadline time.Time) SendOption {
+	return func(o *sendOptions) {
+		o.deadline = deadline
+	}
+}
+
+// SendAttemptTimeout sets how long each message submission attempt may take
+// before it times out. A timed out attempt may be retried as directed by the
+// RetryPolicy.
+func SendAttemptTimeout(timeout time.Duration) SendOption {
+	return func(o *sendOptions) {
+		o.attemptTimeout = timeout
+	}
+}
+
+// SendRetryPolicy replaces the RetryPolicy for the client. A nil policy
+// disables retries, making a single attempt to submit the message.
+func SendRetryPolicy(policy RetryPolicy) SendOption {
+	return func(o *sendOptions) {
+		o.retryPolicy = policy
+		o.retryPolicySet = true
+	}
+}
+
+// SendHeader sets an additional header on each request made to submit the
+// message. Headers set this way replace the default headers of the same
+// name.
+func SendHeader(key string, value string) SendOption {
+	return func(o *sendOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Set(key, value)
+	}
+}
+
+// SendSkipWebhookURLValidation disables webhook URL validation for the
+// message submission.
+func SendSkipWebhookURLValidation() SendOption {
+	return func(o *sendOptions) {
+		o.skipValidation = true
+	}
+}
+
+// SendIdempotencyKey sets a caller-supplied key identifying the message.
+// The key is used by Deduplicator.SendWithOptions in place of a hash of the
+// prepared payload and by Outbox.SendWithOptions to avoid persisting a
+// message which is already pending. The key is also available to
+// interceptors via SendRequest.IdempotencyKey.
+func SendIdempotencyKey(key string) SendOption {
+	return func(o *sendOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+// newSendOptions returns the settings applied by the given options.
+func newSendOptions(opts []SendOption) *sendOptions {
+	var options sendOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &options
+}
+
+// SendWithOptions submits a given message to a Microsoft Teams channel
+// using the provided webhook URL in the same manner as SendWithContext,
+// applying the given options in place of the client settings for this
+// submission only.
+func (c *TeamsClient) SendWithOptions(ctx context.Context, webhookURL string, message Message, opts ...SendOption) error {
+	options := newSendOptions(opts)
+
+	if !options.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, options.deadline)
+		defer cancel()
+	}
+
+	client := c
+	if options.retryPolicySet || options.skipValidation {
+		client = c.With(func(clone *TeamsClient) {
+			if options.retryPolicySet {
+				clone.retryPolicy = options.retryPolicy
+			}
+			if options.skipValidation {
+				clone.skipWebhookURLValidation = true
+			}
+		})
+	}
+
+	return client.SendWithContext(context.WithValue(ctx, sendOptionsKey{}, options), webhookURL, message)
+}
+
+// sendOptionsKey is the context key for the *sendOptions of a
+// SendWithOptions call.
+type sendOptionsKey struct{}
+
+// sendOptionsFromContext returns the *sendOptions for the given context, or
+// nil if none were given.
+func sendOptionsFromContext(ctx context.Context) *sendOptions {
+	options, _ := ctx.Value(sendOptionsKey{}).(*sendOptions)
+
+	return options
+}
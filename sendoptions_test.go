@@ -0,0 +1,220 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeamsClientSendWithOptions(t *testing.T) {
+	var requests int
+	var keys []string
+	clock := &fakeClock{}
+
+	client := NewTeamsClient(
+		WithHTTPClient(newScriptedTestClient(&requests,
+			scriptedResponse{status: http.StatusServiceUnavailable},
+			scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText},
+		)),
+		WithRetryPolicy(NewConstantBackoff(2, time.Second)),
+		WithClock(clock),
+		WithInterceptors(func(req *SendRequest, next SendHandler) (*http.Response, error) {
+			keys = append(keys, req.IdempotencyKey)
+			assert.Equal(t, "abc", req.HTTPRequest.Header.Get("X-Request-ID"))
+			assert.Equal(t, "custom/1.0", req.HTTPRequest.Header.Get("User-Agent"))
+
+			return next(req)
+		}),
+	)
+
+	msgCard := newTestMessageCard(t)
+
+	err := client.SendWithOptions(context.Background(), "https://outlook.office.com/webhook/xxx", msgCard,
+		SendHeader("X-Request-ID", "abc"),
+		SendHeader("User-Agent", "custom/1.0"),
+		SendIdempotencyKey("key-1"),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, []string{"key-1", "key-1"}, keys)
+
+	// Retries may be disabled for a single message.
+	requests = 0
+	err = client.SendWithOptions(context.Background(), "https://outlook.office.com/webhook/xxx", msgCard,
+		SendHeader("X-Request-ID", "abc"),
+		SendHeader("User-Agent", "custom/1.0"),
+		SendRetryPolicy(nil),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, 1, requests)
+
+	// Client settings are not changed.
+	assert.NotNil(t, client.RetryPolicy())
+	assert.Equal(t, DefaultUserAgent, client.UserAgent())
+}
+
+func TestTeamsClientSendWithOptionsValidation(t *testing.T) {
+	var requests int
+	client := NewTeamsClient(WithHTTPClient(newScriptedTestClient(&requests,
+		scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText},
+	)))
+
+	msgCard := newTestMessageCard(t)
+
+	err := client.SendWithOptions(context.Background(), "https://example.com/webhook", msgCard)
+	assert.True(t, errors.Is(err, ErrWebhookURLUnexpected))
+
+	err = client.SendWithOptions(context.Background(), "https://example.com/webhook", msgCard,
+		SendSkipWebhookURLValidation(),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+
+	assert.True(t, errors.Is(client.ValidateWebhook("https://example.com/webhook"), ErrWebhookURLUnexpected))
+}
+
+func TestTeamsClientSendWithOptionsTimeouts(t *testing.T) {
+	var attempts int
+	client := NewTeamsClient(
+		WithRetryPolicy(NewConstantBackoff(1, time.Millisecond)),
+		WithHTTPClient(NewTestClient(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			<-req.Context().Done()
+
+			return nil, req.Context().Err()
+		})),
+	)
+
+	msgCard := newTestMessageCard(t)
+
+	// Each attempt times out and is retried.
+	err := client.SendWithOptions(context.Background(), "https://outlook.office.com/webhook/xxx", msgCard,
+		SendAttemptTimeout(10*time.Millisecond),
+	)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 2, attempts)
+
+	// The overall deadline stops further attempts.
+	attempts = 0
+	start := time.Now()
+	err = client.SendWithOptions(context.Background(), "https://outlook.office.com/webhook/xxx", msgCard,
+		SendDeadline(time.Now().Add(20*time.Millisecond)),
+		SendRetryPolicy(NewConstantBackoff(5, time.Second)),
+	)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestSendIdempotencyKeyLayers(t *testing.T) {
+	var requests int
+	failing := NewTeamsClient(WithHTTPClient(newScriptedTestClient(&requests,
+		scriptedResponse{status: http.StatusServiceUnavailable},
+	)))
+	working := failing.With(WithHTTPClient(newScriptedTestClient(&requests,
+		scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText},
+	)))
+
+	webhookURL := "https://outlook.office.com/webhook/xxx"
+	ctx := context.Background()
+
+	// Different messages with the same key are treated as repeats.
+	first := newTestMessageCard(t)
+	second := newTestMessageCard(t)
+	second.Text = "Different text"
+
+	dedup := NewDeduplicator(working, DedupConfig{SweepInterval: -1})
+	defer dedup.Close()
+
+	assert.NoError(t, dedup.SendWithOptions(ctx, webhookURL, first, SendIdempotencyKey("job-1")))
+	assert.NoError(t, dedup.SendWithOptions(ctx, webhookURL, second, SendIdempotencyKey("job-1")))
+	assert.Equal(t, 1, requests)
+
+	// Messages with the same key are only persisted once by an Outbox.
+	dir, err := ioutil.TempDir("", "outbox")
+	requireNoError(t, err)
+	defer os.RemoveAll(dir)
+
+	requests = 0
+	outbox, err := OpenOutbox(dir, failing, OutboxConfig{})
+	requireNoError(t, err)
+
+	assert.Error(t, outbox.SendWithOptions(ctx, webhookURL, first, SendIdempotencyKey("job-1")))
+	assert.NoError(t, outbox.SendWithOptions(ctx, webhookURL, second, SendIdempotencyKey("job-1")))
+	assert.Equal(t, 1, requests)
+	requireNoError(t, outbox.Close())
+
+	// The key is kept for pending messages across restarts.
+	outbox, err = OpenOutbox(dir, working, OutboxConfig{})
+	requireNoError(t, err)
+	defer outbox.Close()
+
+	pending := outbox.Pending()
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "job-1", pending[0].IdempotencyKey)
+	}
+
+	assert.NoError(t, outbox.SendWithOptions(ctx, webhookURL, second, SendIdempotencyKey("job-1")))
+
+	delivered, err := outbox.Replay(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Empty(t, outbox.Pending())
+}
+
+func TestOutboxSendWithOptionsSkipValidation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	requireNoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var requests int
+	failing := NewTeamsClient(WithHTTPClient(newScriptedTestClient(&requests,
+		scriptedResponse{status: http.StatusServiceUnavailable},
+	)))
+	working := failing.With(WithHTTPClient(newScriptedTestClient(&requests,
+		scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText},
+	)))
+
+	ctx := context.Background()
+	msgCard := newTestMessageCard(t)
+
+	outbox, err := OpenOutbox(dir, failing, OutboxConfig{})
+	requireNoError(t, err)
+
+	err = outbox.SendWithOptions(ctx, "https://example.com/webhook", msgCard)
+	assert.True(t, errors.Is(err, ErrWebhookURLUnexpected))
+	assert.Equal(t, 0, requests)
+	assert.Empty(t, outbox.Pending())
+
+	assert.Error(t, outbox.SendWithOptions(ctx, "https://example.com/webhook", msgCard,
+		SendSkipWebhookURLValidation(),
+	))
+	assert.Equal(t, 1, requests)
+	assert.Len(t, outbox.Pending(), 1)
+	requireNoError(t, outbox.Close())
+
+	// Pending messages are replayed without validation after a restart.
+	requests = 0
+	outbox, err = OpenOutbox(dir, working, OutboxConfig{})
+	requireNoError(t, err)
+	defer outbox.Close()
+
+	delivered, err := outbox.Replay(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, 1, requests)
+	assert.Empty(t, outbox.Pending())
+}
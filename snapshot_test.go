@@ -0,0 +1,221 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rmasci/go-teams-notify/v2/botapi"
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMessageCard(t *testing.T) *messagecard.MessageCard {
+	t.Helper()
+
+	card := messagecard.NewMessageCard()
+	card.Text = "Hello World"
+
+	section := messagecard.NewSection()
+	requireNoError(t, section.AddFactFromKeyValue("Status", "passed"))
+	requireNoError(t, section.AddHeroImageStr("https://example.com/hero.png", "hero"))
+	requireNoError(t, card.AddSection(section))
+
+	action, err := messagecard.NewPotentialAction(messagecard.PotentialActionOpenURIType, "View")
+	requireNoError(t, err)
+	action.PotentialActionOpenURI.Targets = []messagecard.PotentialActionOpenURITarget{
+		{OS: "default", URI: "https://example.com"},
+	}
+	requireNoError(t, card.AddPotentialAction(action))
+
+	return card
+}
+
+func TestMessageCardClone(t *testing.T) {
+	card := newTestMessageCard(t)
+	requireNoError(t, card.Prepare(false))
+	original := card.PrettyPrint()
+
+	clone := card.Clone()
+	assert.Equal(t, original, clone.PrettyPrint())
+
+	clone.Text = "Goodbye World"
+	clone.Sections[0].Facts[0].Value = "failed"
+	clone.Sections[0].HeroImage.Image = "https://example.com/other.png"
+	clone.PotentialActions[0].Targets[0].URI = "https://example.com/other"
+	requireNoError(t, clone.Sections[0].AddFactFromKeyValue("Duration", "1m"))
+
+	requireNoError(t, card.Prepare(false))
+	assert.Equal(t, original, card.PrettyPrint())
+
+	assert.Nil(t, (*messagecard.MessageCard)(nil).Clone())
+}
+
+func TestPotentialActionCloneNestedActions(t *testing.T) {
+	action, err := messagecard.NewPotentialAction(messagecard.PotentialActionActionCardType, "Respond")
+	requireNoError(t, err)
+
+	openURI := messagecard.PotentialActionActionCardAction{Type: messagecard.PotentialActionOpenURIType, Name: "View"}
+	openURI.Targets = []messagecard.PotentialActionOpenURITarget{{OS: "default", URI: "https://example.com"}}
+
+	httpPOST := messagecard.PotentialActionActionCardAction{Type: messagecard.PotentialActionHTTPPostType, Name: "Ack"}
+	httpPOST.Target = "https://example.com/ack"
+	httpPOST.Headers = []messagecard.PotentialActionHTTPPOSTHeader{{Name: "X-Token", Value: "abc"}}
+
+	action.Actions = []messagecard.PotentialActionActionCardAction{openURI, httpPOST}
+
+	clone := action.Clone()
+	clone.Actions[0].Targets[0].URI = "https://changed"
+	clone.Actions[1].Headers[0].Value = "changed"
+
+	assert.Equal(t, "https://example.com", action.Actions[0].Targets[0].URI)
+	assert.Equal(t, "abc", action.Actions[1].Headers[0].Value)
+}
+
+func TestBotMessageClone(t *testing.T) {
+	msg := botapi.NewMessage().AddText("Hello")
+	requireNoError(t, msg.Mention("Some User", "some.user@example.com", true))
+
+	clone := msg.Clone()
+	clone.Entities[0].Mentioned.Name = "Other User"
+	clone.AddText(" again")
+
+	assert.Equal(t, "Some User", msg.Entities[0].Mentioned.Name)
+	assert.Equal(t, "<at>Some User</at> Hello", msg.Text)
+}
+
+func TestMessageCardSnapshot(t *testing.T) {
+	card := newTestMessageCard(t)
+
+	snapshot, err := card.Snapshot()
+	requireNoError(t, err)
+
+	card.Text = "Goodbye World"
+
+	payload, err := ioutil.ReadAll(snapshot.Payload())
+	requireNoError(t, err)
+	assert.Contains(t, string(payload), "Hello World")
+	assert.Equal(t, payload, snapshot.Bytes())
+
+	// Snapshots do not prepare the card.
+	assert.Empty(t, card.PrettyPrint())
+}
+
+func TestPrepareAfterModification(t *testing.T) {
+	card := newTestMessageCard(t)
+	requireNoError(t, card.Prepare(false))
+
+	card.Text = "Goodbye World"
+	requireNoError(t, card.Prepare(false))
+	assert.Contains(t, card.PrettyPrint(), "Goodbye World")
+
+	// Adding content invalidates the prepared payload.
+	requireNoError(t, card.AddSection(&messagecard.Section{Text: "More"}))
+	assert.Empty(t, card.PrettyPrint())
+
+	msg := botapi.NewMessage().AddText("Hello")
+	requireNoError(t, msg.Prepare(false))
+	msg.Text = "Goodbye"
+	requireNoError(t, msg.Prepare(false))
+	assert.Contains(t, msg.PrettyPrint(), "Goodbye")
+}
+
+func TestTeamsClientConcurrentSendSameMessage(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+
+	client := NewTeamsClient().SetHTTPClient(NewTestClient(func(req *http.Request) (*http.Response, error) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(ExpectedWebhookURLResponseText)),
+			Header:     make(http.Header),
+		}, nil
+	}))
+
+	card := newTestMessageCard(t)
+	msg := botapi.NewMessage().AddText("Hello")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, client.SendWithContext(context.Background(), "https://outlook.office.com/webhook/xxx", card))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, client.SendWithContext(context.Background(), "https://outlook.office.com/webhook/xxx", msg))
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, received, 16)
+
+	// Later modifications are submitted.
+	card.Text = "Goodbye World"
+	assert.NoError(t, client.Send("https://outlook.office.com/webhook/xxx", card))
+	assert.Contains(t, received[len(received)-1], "Goodbye World")
+}
+
+func TestTeamsClientConcurrentSendDeprecatedMessageCard(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+
+	client := NewTeamsClient().SetHTTPClient(NewTestClient(func(req *http.Request) (*http.Response, error) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(ExpectedWebhookURLResponseText)),
+			Header:     make(http.Header),
+		}, nil
+	}))
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+	requireNoError(t, msgCard.Prepare(false))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, client.Send("https://outlook.office.com/webhook/xxx", &msgCard))
+		}()
+	}
+	wg.Wait()
+
+	// The previously prepared payload is not submitted once stale.
+	msgCard.Text = "Goodbye World"
+	assert.NoError(t, client.Send("https://outlook.office.com/webhook/xxx", &msgCard))
+	assert.Contains(t, received[len(received)-1], "Goodbye World")
+
+	requireNoError(t, msgCard.Prepare(false))
+	assert.Contains(t, msgCard.PrettyPrint(), "Goodbye World")
+}
@@ -0,0 +1,365 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package teamstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrNoMatchingInteraction indicates that a request made while replaying a
+// cassette does not match any remaining recorded interaction.
+var ErrNoMatchingInteraction = errors.New("no matching interaction in cassette")
+
+// redactedPath replaces the path and query of recorded URLs.
+const redactedPath string = "/REDACTED"
+
+// CassetteMode controls how a Cassette handles requests.
+type CassetteMode int
+
+const (
+	// ModeReplay responds to requests using recorded interactions without
+	// contacting the remote endpoint.
+	ModeReplay CassetteMode = iota
+
+	// ModeRecord submits requests to the remote endpoint and records each
+	// interaction.
+	ModeRecord
+
+	// ModePassthrough submits requests to the remote endpoint without
+	// recording them.
+	ModePassthrough
+)
+
+// String returns the name of the mode.
+func (m CassetteMode) String() string {
+	switch m {
+	case ModeReplay:
+		return "replay"
+	case ModeRecord:
+		return "record"
+	case ModePassthrough:
+		return "passthrough"
+	default:
+		return fmt.Sprintf("CassetteMode(%d)", int(m))
+	}
+}
+
+// Interaction is a recorded request and response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded request. The URL is redacted to the scheme
+// and host as webhook URLs contain secrets.
+type RecordedRequest struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// RecordedResponse is a recorded response.
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// cassetteFile is the JSON document stored for a Cassette.
+type cassetteFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Cassette is an http.RoundTripper which records interactions with a
+// remote endpoint to a JSON file and replays them later. Install it by
+// passing the http.Client returned by Client to the WithHTTPClient option:
+//
+//	client := goteamsnotify.NewTeamsClient(
+//		goteamsnotify.WithHTTPClient(cassette.Client()),
+//	)
+//
+// Requests are matched on method, redacted URL and normalized JSON body;
+// each recorded interaction is replayed once, in order. A request without a
+// matching interaction fails with ErrNoMatchingInteraction. A Cassette is
+// safe for concurrent use.
+type Cassette struct {
+	path      string
+	mode      CassetteMode
+	transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	played       []bool
+}
+
+// NewCassette creates a Cassette using the file at the given path in the
+// given mode. The file must exist for ModeReplay; it is replaced by the
+// recorded interactions in ModeRecord.
+func NewCassette(path string, mode CassetteMode) (*Cassette, error) {
+	c := Cassette{
+		path:      path,
+		mode:      mode,
+		transport: http.DefaultTransport,
+	}
+
+	if mode == ModeReplay {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+
+		var file cassetteFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to decode cassette %q: %w", path, err)
+		}
+
+		c.interactions = file.Interactions
+		c.played = make([]bool, len(file.Interactions))
+	}
+
+	return &c, nil
+}
+
+// SetTransport accepts an http.RoundTripper which replaces
+// http.DefaultTransport for requests submitted to the remote endpoint in
+// ModeRecord and ModePassthrough.
+func (c *Cassette) SetTransport(transport http.RoundTripper) *Cassette {
+	c.transport = transport
+
+	return c
+}
+
+// Client returns an http.Client which uses the Cassette.
+func (c *Cassette) Client() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// Mode returns the mode of the Cassette.
+func (c *Cassette) Mode() CassetteMode {
+	return c.mode
+}
+
+// Interactions returns the recorded interactions.
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Interaction(nil), c.interactions...)
+}
+
+// Unplayed returns the number of recorded interactions which have not been
+// replayed.
+func (c *Cassette) Unplayed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int
+	for _, played := range c.played {
+		if !played {
+			n++
+		}
+	}
+
+	return n
+}
+
+// RoundTrip handles the given request according to the mode of the
+// Cassette.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch c.mode {
+	case ModePassthrough:
+		return c.transport.RoundTrip(req)
+	case ModeRecord:
+		return c.record(req)
+	case ModeReplay:
+		return c.replay(req)
+	default:
+		return nil, fmt.Errorf("unsupported cassette mode %v", c.mode)
+	}
+}
+
+// record submits the given request and records the interaction.
+func (c *Cassette) record(req *http.Request) (*http.Response, error) {
+	recorded, body, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The request is cloned as a RoundTripper must not modify the request
+	// provided by the caller.
+	forwarded := req.Clone(req.Context())
+	forwarded.Body = ioutil.NopCloser(bytes.NewReader(body))
+	forwarded.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	forwarded.ContentLength = int64(len(body))
+
+	res, err := c.transport.RoundTrip(forwarded)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+
+	header := res.Header.Clone()
+	header.Del("Set-Cookie")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.interactions = append(c.interactions, Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: res.StatusCode,
+			Header:     header,
+			Body:       string(resBody),
+		},
+	})
+	c.played = append(c.played, true)
+
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// replay responds to the given request using the first matching recorded
+// interaction which has not been replayed.
+func (c *Cassette) replay(req *http.Request) (*http.Response, error) {
+	recorded, _, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, interaction := range c.interactions {
+		if c.played[i] || !matches(interaction.Request, recorded) {
+			continue
+		}
+		c.played[i] = true
+
+		res := interaction.Response
+		header := res.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+
+		return &http.Response{
+			StatusCode:    res.StatusCode,
+			Status:        fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)),
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(strings.NewReader(res.Body)),
+			ContentLength: int64(len(res.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf(
+		"%w %q: %s %s %s",
+		ErrNoMatchingInteraction,
+		c.path,
+		recorded.Method,
+		recorded.URL,
+		recorded.Body,
+	)
+}
+
+// save writes the recorded interactions to the cassette file.
+func (c *Cassette) save() error {
+	data, err := json.MarshalIndent(cassetteFile{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+
+	if err := ioutil.WriteFile(c.path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	return nil
+}
+
+// recordRequest returns the recorded form of the given request along with
+// the request body.
+func recordRequest(req *http.Request) (RecordedRequest, []byte, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return RecordedRequest{}, nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	normalized, err := normalizeBody(body)
+	if err != nil {
+		return RecordedRequest{}, nil, err
+	}
+
+	return RecordedRequest{
+		Method: req.Method,
+		URL:    redactURL(req.URL),
+		Body:   normalized,
+	}, body, nil
+}
+
+// matches indicates whether the recorded request matches the given request.
+func matches(recorded RecordedRequest, req RecordedRequest) bool {
+	if recorded.Method != req.Method || recorded.URL != req.URL {
+		return false
+	}
+
+	normalized, err := normalizeBody(recorded.Body)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(normalized, req.Body)
+}
+
+// normalizeBody returns the given body as compact JSON with sorted object
+// keys. A body which is not JSON is returned as a JSON string.
+func normalizeBody(body []byte) (json.RawMessage, error) {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		value = string(body)
+	}
+
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize request body: %w", err)
+	}
+
+	return normalized, nil
+}
+
+// redactURL returns the scheme and host of the given URL, omitting the path
+// and query which contain secrets.
+func redactURL(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + redactedPath
+}
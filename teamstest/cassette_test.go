@@ -0,0 +1,126 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package teamstest_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	goteamsnotify "github.com/rmasci/go-teams-notify/v2"
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+	"github.com/rmasci/go-teams-notify/v2/teamstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCassette(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassette.json")
+
+	server := teamstest.NewServer(teamstest.ServerError())
+	webhookURL := server.WebhookURL()
+
+	newClient := func(cassette *teamstest.Cassette) *goteamsnotify.TeamsClient {
+		return server.NewClient().With(
+			goteamsnotify.WithHTTPClient(cassette.Client()),
+			goteamsnotify.WithRetryPolicy(goteamsnotify.NewConstantBackoff(1, time.Millisecond)),
+		)
+	}
+
+	msgCard := messagecard.NewMessageCard()
+	msgCard.Title = "Greeting"
+	msgCard.Text = "Hello World"
+
+	// Record the exchange with the remote endpoint.
+	recorder, err := teamstest.NewCassette(path, teamstest.ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, newClient(recorder).SendWithContext(context.Background(), webhookURL, msgCard))
+	assert.Len(t, recorder.Interactions(), 2)
+	server.AssertRequestCount(t, 2)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(data), server.URL+"/REDACTED")
+	assert.NotContains(t, string(data), teamstest.WebhookPath)
+
+	server.Close()
+
+	// Replay the exchange without the remote endpoint.
+	player, err := teamstest.NewCassette(path, teamstest.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(player)
+	assert.NoError(t, client.SendWithContext(context.Background(), webhookURL, msgCard))
+	assert.Equal(t, 0, player.Unplayed())
+
+	// Interactions are replayed once.
+	err = client.SendWithContext(context.Background(), webhookURL, msgCard)
+	assert.True(t, errors.Is(err, teamstest.ErrNoMatchingInteraction))
+
+	// Requests are matched on the message content.
+	player, err = teamstest.NewCassette(path, teamstest.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCard := messagecard.NewMessageCard()
+	otherCard.Text = "Goodbye World"
+	err = newClient(player).SendWithContext(context.Background(), webhookURL, otherCard)
+	assert.True(t, errors.Is(err, teamstest.ErrNoMatchingInteraction))
+	assert.Contains(t, err.Error(), "Goodbye World")
+
+	_, err = teamstest.NewCassette(filepath.Join(dir, "missing.json"), teamstest.ModeReplay)
+	assert.Error(t, err)
+}
+
+func TestCassetteRecordRequest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := teamstest.NewServer()
+	defer server.Close()
+
+	recorder, err := teamstest.NewCassette(filepath.Join(dir, "cassette.json"), teamstest.ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := ioutil.NopCloser(strings.NewReader(`{"text":"Hello World"}`))
+	req, err := http.NewRequest(http.MethodPost, server.WebhookURL(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := recorder.Client().Transport.RoundTrip(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_ = res.Body.Close()
+
+	// The request provided by the caller is not modified.
+	assert.Equal(t, body, req.Body)
+	assert.Nil(t, req.GetBody)
+	assert.Len(t, recorder.Interactions(), 1)
+}
@@ -0,0 +1,46 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+/*
+Package teamstest provides an in-process fake Microsoft Teams incoming webhook
+for use in tests.
+
+A Server accepts messages in the same manner as a Microsoft Teams webhook URL,
+returning "1" on success and reproducing the error responses returned by
+Microsoft Teams for payloads which are not valid. Other responses (e.g., rate
+limiting or a removed connector) are provided using a script. Each message
+received is recorded, decoded into the messagecard or botapi types, for use
+with the provided assertion helpers.
+
+	server := teamstest.NewServer(teamstest.RateLimited(time.Second))
+	defer server.Close()
+
+	msgCard := messagecard.NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	client := server.NewClient()
+	if err := client.Send(server.WebhookURL(), msgCard); err != nil {
+		// ...
+	}
+
+	server.AssertRequestCount(t, 1)
+	server.AssertReceivedText(t, "Hello World")
+
+A Cassette records exchanges with a real webhook URL to a JSON file (with
+webhook URL secrets redacted) so that they can be replayed in later tests
+without network access.
+
+	cassette, err := teamstest.NewCassette("testdata/greeting.json", teamstest.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := goteamsnotify.NewTeamsClient(
+		goteamsnotify.WithHTTPClient(cassette.Client()),
+	)
+*/
+package teamstest
@@ -0,0 +1,412 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package teamstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	goteamsnotify "github.com/rmasci/go-teams-notify/v2"
+	"github.com/rmasci/go-teams-notify/v2/botapi"
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+)
+
+// WebhookPath is the path of the webhook URL provided by a Server.
+const WebhookPath string = "/webhookb2/teamstest@teamstest/IncomingWebhook/teamstest/teamstest"
+
+// Response texts returned by Microsoft Teams.
+const (
+	// SuccessResponseText is returned for a successful message submission.
+	SuccessResponseText string = goteamsnotify.ExpectedWebhookURLResponseText
+
+	// BadPayloadResponseText is returned for a payload which is not valid
+	// JSON.
+	BadPayloadResponseText string = "Bad payload received by generic incoming webhook."
+
+	// SummaryOrTextRequiredResponseText is returned for a MessageCard
+	// without a summary or text.
+	SummaryOrTextRequiredResponseText string = "Summary or Text is required."
+
+	// TextRequiredResponseText is returned for a message without text.
+	TextRequiredResponseText string = "Text is required."
+
+	// ConnectorRemovedResponseText is returned once the connector for a
+	// webhook URL has been removed.
+	ConnectorRemovedResponseText string = "Connector configuration not found"
+)
+
+// deliveryFailedFormat is the format of the response text returned with a
+// 200 status code when delivery fails within Microsoft Teams.
+const deliveryFailedFormat string = "Webhook message delivery failed with error: " +
+	"Microsoft Teams endpoint returned HTTP error %d with ContextId " +
+	"tcid=0,server=teamstest,cv=teamstest."
+
+// Response is a scripted response returned by a Server.
+type Response struct {
+	// StatusCode is the HTTP status code. If not set, http.StatusOK is used.
+	StatusCode int
+
+	// Body is the response text. If not set for a successful status code,
+	// SuccessResponseText is used.
+	Body string
+
+	// RetryAfter is returned using the Retry-After header, rounded up to
+	// whole seconds.
+	RetryAfter time.Duration
+
+	// Latency delays the response.
+	Latency time.Duration
+}
+
+// Success returns a Response for a successful message submission.
+func Success() Response {
+	return Response{StatusCode: http.StatusOK, Body: SuccessResponseText}
+}
+
+// RateLimited returns a Response indicating that the webhook URL is being
+// throttled, requesting that the client waits for the given duration.
+func RateLimited(retryAfter time.Duration) Response {
+	return Response{
+		StatusCode: http.StatusTooManyRequests,
+		Body:       fmt.Sprintf(deliveryFailedFormat, http.StatusTooManyRequests),
+		RetryAfter: retryAfter,
+	}
+}
+
+// DeliveryFailed returns a Response reporting the given status code within
+// the response text of a 200 status code in the same manner as Microsoft
+// Teams.
+func DeliveryFailed(statusCode int) Response {
+	return Response{
+		StatusCode: http.StatusOK,
+		Body:       fmt.Sprintf(deliveryFailedFormat, statusCode),
+	}
+}
+
+// ConnectorRemoved returns a Response indicating that the connector for the
+// webhook URL has been removed.
+func ConnectorRemoved() Response {
+	return Response{StatusCode: http.StatusGone, Body: ConnectorRemovedResponseText}
+}
+
+// ServerError returns a Response indicating a failure within Microsoft
+// Teams.
+func ServerError() Response {
+	return Response{
+		StatusCode: http.StatusInternalServerError,
+		Body:       fmt.Sprintf(deliveryFailedFormat, http.StatusInternalServerError),
+	}
+}
+
+// WithLatency returns a copy of the Response which is delayed by the given
+// duration.
+func (r Response) WithLatency(latency time.Duration) Response {
+	r.Latency = latency
+
+	return r
+}
+
+// Request is a request received by a Server.
+type Request struct {
+	// Header is the request header.
+	Header http.Header
+
+	// Body is the request body.
+	Body []byte
+
+	// MessageCard is the decoded message if a MessageCard was received.
+	MessageCard *messagecard.MessageCard
+
+	// BotMessage is the decoded message if a botapi Message was received.
+	BotMessage *botapi.Message
+
+	// Response is the response returned for the request.
+	Response Response
+}
+
+// Server is a fake Microsoft Teams incoming webhook. A Server is safe for
+// concurrent use.
+type Server struct {
+	// URL is the base URL of the server.
+	URL string
+
+	server *httptest.Server
+
+	mu       sync.Mutex
+	script   []Response
+	requests []Request
+}
+
+// NewServer starts a Server which returns the given responses in order for
+// valid messages. Once the scripted responses are exhausted, valid messages
+// are accepted. The caller should call Close when finished.
+func NewServer(responses ...Response) *Server {
+	s := &Server{script: responses}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// WebhookURL returns the webhook URL for the server.
+func (s *Server) WebhookURL() string {
+	return s.URL + WebhookPath
+}
+
+// NewClient returns a TeamsClient which submits messages to the server.
+// Webhook URL validation is relaxed to accept the server URL.
+func (s *Server) NewClient() *goteamsnotify.TeamsClient {
+	return goteamsnotify.NewTeamsClient(
+		goteamsnotify.WithHTTPClient(s.server.Client()),
+		goteamsnotify.WithWebhookURLValidationPatterns("^"+regexp.QuoteMeta(s.URL+"/")),
+	)
+}
+
+// Script appends responses to be returned for subsequent valid messages.
+func (s *Server) Script(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.script = append(s.script, responses...)
+}
+
+// Requests returns the requests received by the server.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// MessageCards returns the MessageCards received by the server.
+func (s *Server) MessageCards() []*messagecard.MessageCard {
+	var cards []*messagecard.MessageCard
+	for _, req := range s.Requests() {
+		if req.MessageCard != nil {
+			cards = append(cards, req.MessageCard)
+		}
+	}
+
+	return cards
+}
+
+// BotMessages returns the botapi Messages received by the server.
+func (s *Server) BotMessages() []*botapi.Message {
+	var messages []*botapi.Message
+	for _, req := range s.Requests() {
+		if req.BotMessage != nil {
+			messages = append(messages, req.BotMessage)
+		}
+	}
+
+	return messages
+}
+
+// Reset discards recorded requests and any remaining scripted responses.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.script = nil
+	s.requests = nil
+}
+
+// AssertRequestCount reports an error if the number of requests received by
+// the server is not the given number.
+func (s *Server) AssertRequestCount(t testing.TB, want int) bool {
+	t.Helper()
+
+	if got := len(s.Requests()); got != want {
+		t.Errorf("teamstest: got %d requests, expected %d", got, want)
+
+		return false
+	}
+
+	return true
+}
+
+// AssertReceivedText reports an error if the given text is not included in
+// the text of a message received by the server.
+func (s *Server) AssertReceivedText(t testing.TB, text string) bool {
+	t.Helper()
+
+	for _, req := range s.Requests() {
+		for _, received := range messageTexts(req) {
+			if strings.Contains(received, text) {
+				return true
+			}
+		}
+	}
+
+	t.Errorf("teamstest: no message received containing text %q", text)
+
+	return false
+}
+
+// LastMessageCard returns the most recent MessageCard received by the
+// server, reporting a fatal error if none were received.
+func (s *Server) LastMessageCard(t testing.TB) *messagecard.MessageCard {
+	t.Helper()
+
+	cards := s.MessageCards()
+	if len(cards) == 0 {
+		t.Fatal("teamstest: no MessageCard received")
+	}
+
+	return cards[len(cards)-1]
+}
+
+// handle responds to a submitted message.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	req := Request{
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+
+	// Payloads which are not valid are rejected without using the script.
+	res, valid := decode(&req)
+	if valid {
+		res = s.next()
+	}
+	req.Response = res
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	if res.Latency > 0 {
+		select {
+		case <-time.After(res.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if res.RetryAfter > 0 {
+		seconds := int(math.Ceil(res.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(res.StatusCode)
+	_, _ = w.Write([]byte(res.Body))
+}
+
+// next returns the next scripted response.
+func (s *Server) next() Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := Success()
+	if len(s.script) > 0 {
+		res = s.script[0]
+		s.script = s.script[1:]
+	}
+
+	if res.StatusCode == 0 {
+		res.StatusCode = http.StatusOK
+	}
+
+	if res.Body == "" && res.StatusCode < 300 {
+		res.Body = SuccessResponseText
+	}
+
+	return res
+}
+
+// decode decodes the message in the given request, returning the response
+// for a payload which is not valid.
+func decode(req *Request) (Response, bool) {
+	badRequest := func(text string) (Response, bool) {
+		return Response{StatusCode: http.StatusBadRequest, Body: text}, false
+	}
+
+	var envelope struct {
+		Type       string `json:"type"`
+		SchemaType string `json:"@type"`
+	}
+	if err := json.Unmarshal(req.Body, &envelope); err != nil {
+		return badRequest(BadPayloadResponseText)
+	}
+
+	switch {
+	case strings.EqualFold(envelope.SchemaType, "MessageCard"):
+		var card messagecard.MessageCard
+		if err := json.Unmarshal(req.Body, &card); err != nil {
+			return badRequest(BadPayloadResponseText)
+		}
+		req.MessageCard = &card
+
+		if card.Text == "" && card.Summary == "" {
+			return badRequest(SummaryOrTextRequiredResponseText)
+		}
+
+	case envelope.Type == "message":
+		var msg botapi.Message
+		if err := json.Unmarshal(req.Body, &msg); err != nil {
+			return badRequest(BadPayloadResponseText)
+		}
+		req.BotMessage = &msg
+
+		if msg.Text == "" {
+			return badRequest(TextRequiredResponseText)
+		}
+
+	default:
+		return badRequest(BadPayloadResponseText)
+	}
+
+	return Response{}, true
+}
+
+// messageTexts returns the text content of the message in the given
+// request.
+func messageTexts(req Request) []string {
+	var texts []string
+
+	if card := req.MessageCard; card != nil {
+		texts = append(texts, card.Title, card.Summary, card.Text)
+		for _, section := range card.Sections {
+			if section == nil {
+				continue
+			}
+			texts = append(texts, section.Title, section.ActivityTitle, section.ActivityText, section.Text)
+			for _, fact := range section.Facts {
+				texts = append(texts, fact.Name, fact.Value)
+			}
+		}
+	}
+
+	if msg := req.BotMessage; msg != nil {
+		texts = append(texts, msg.Text)
+	}
+
+	return texts
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package teamstest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	goteamsnotify "github.com/rmasci/go-teams-notify/v2"
+	"github.com/rmasci/go-teams-notify/v2/botapi"
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+	"github.com/rmasci/go-teams-notify/v2/teamstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	server := teamstest.NewServer(
+		teamstest.RateLimited(time.Second),
+		teamstest.ServerError().WithLatency(10*time.Millisecond),
+	)
+	defer server.Close()
+
+	client := server.NewClient().
+		SetRetryPolicy(goteamsnotify.NewConstantBackoff(2, time.Millisecond))
+
+	msgCard := messagecard.NewMessageCard()
+	msgCard.Title = "Greeting"
+	msgCard.Text = "Hello World"
+
+	start := time.Now()
+	assert.NoError(t, client.SendWithContext(context.Background(), server.WebhookURL(), msgCard))
+	assert.True(t, time.Since(start) >= time.Second, "Retry-After should be honored")
+
+	server.AssertRequestCount(t, 3)
+	server.AssertReceivedText(t, "Hello World")
+	assert.Equal(t, "Greeting", server.LastMessageCard(t).Title)
+
+	requests := server.Requests()
+	assert.Equal(t, http.StatusTooManyRequests, requests[0].Response.StatusCode)
+	assert.Equal(t, http.StatusInternalServerError, requests[1].Response.StatusCode)
+	assert.Equal(t, teamstest.SuccessResponseText, requests[2].Response.Body)
+
+	// Messages which are not valid are rejected using the Microsoft Teams
+	// response text.
+	server.Reset()
+	err := client.SkipWebhookURLValidationOnSend(true).
+		SendWithContext(context.Background(), server.WebhookURL(), goteamsnotify.NewRawMessage([]byte(`{"@type":"MessageCard"}`)))
+	assert.True(t, errors.Is(err, goteamsnotify.ErrBadRequest))
+	assert.Contains(t, err.Error(), teamstest.SummaryOrTextRequiredResponseText)
+	assert.Len(t, server.MessageCards(), 1)
+
+	server.Script(teamstest.ConnectorRemoved())
+	err = client.SendWithContext(context.Background(), server.WebhookURL(), msgCard)
+	assert.True(t, errors.Is(err, goteamsnotify.ErrConnectorRemoved))
+
+	server.Script(teamstest.DeliveryFailed(http.StatusRequestEntityTooLarge))
+	err = client.SendWithContext(context.Background(), server.WebhookURL(), msgCard)
+	assert.True(t, errors.Is(err, goteamsnotify.ErrPayloadTooLarge))
+}
+
+func TestServerBotMessage(t *testing.T) {
+	server := teamstest.NewServer()
+	defer server.Close()
+
+	msg := botapi.NewMessage().AddText("Hello")
+	assert.NoError(t, msg.Mention("Some User", "some.user@example.com", true))
+
+	assert.NoError(t, server.NewClient().Send(server.WebhookURL(), msg))
+
+	messages := server.BotMessages()
+	if assert.Len(t, messages, 1) {
+		assert.Len(t, messages[0].Entities, 1)
+	}
+	server.AssertReceivedText(t, "<at>Some User</at>")
+
+	// Other webhook URLs are still rejected.
+	err := server.NewClient().Send("https://example.com/webhook", msg)
+	assert.True(t, errors.Is(err, goteamsnotify.ErrWebhookURLUnexpected))
+}
+
+func TestServerCircuitBreakerTimeouts(t *testing.T) {
+	server := teamstest.NewServer()
+	defer server.Close()
+
+	breaker := goteamsnotify.NewCircuitBreaker(goteamsnotify.CircuitBreakerConfig{FailureThreshold: 2})
+	client := server.NewClient().With(goteamsnotify.WithCircuitBreaker(breaker))
+
+	msgCard := messagecard.NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	// Attempts which time out waiting for the endpoint are failures.
+	server.Script(
+		teamstest.Success().WithLatency(time.Second),
+		teamstest.Success().WithLatency(time.Second),
+	)
+
+	err := client.SendWithOptions(context.Background(), server.WebhookURL(), msgCard,
+		goteamsnotify.SendAttemptTimeout(20*time.Millisecond),
+	)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, goteamsnotify.CircuitClosed, breaker.State(server.WebhookURL()))
+
+	err = client.With(goteamsnotify.WithSendTimeout(20*time.Millisecond)).Send(server.WebhookURL(), msgCard)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, goteamsnotify.CircuitOpen, breaker.State(server.WebhookURL()))
+
+	// Cancellation by the caller is not a failure.
+	breaker.Reset(server.WebhookURL())
+	server.Script(
+		teamstest.Success().WithLatency(time.Second),
+		teamstest.Success().WithLatency(time.Second),
+	)
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		err = client.SendWithContext(ctx, server.WebhookURL(), msgCard)
+		assert.True(t, errors.Is(err, context.Canceled))
+		cancel()
+	}
+	assert.Equal(t, goteamsnotify.CircuitClosed, breaker.State(server.WebhookURL()))
+}
@@ -0,0 +1,306 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Names of the rules provided by this package. These are reported by
+// WebhookValidationError.
+const (
+	WebhookRuleParse     = "parse"
+	WebhookRuleHTTPS     = "https"
+	WebhookRuleHosts     = "hosts"
+	WebhookRulePathShape = "path-shape"
+	WebhookRulePattern   = "pattern"
+	WebhookRuleAnyOf     = "any-of"
+)
+
+// WebhookValidationError is returned by WebhookValidator.Validate when a
+// webhook URL fails a rule. The error matches ErrWebhookURLUnexpected when
+// used with errors.Is.
+type WebhookValidationError struct {
+	// Rule is the name of the rule which failed.
+	Rule string
+
+	// Err is the reason given by the rule.
+	Err error
+}
+
+// Error provides a description of the failure.
+func (e *WebhookValidationError) Error() string {
+	return fmt.Sprintf("%v; rule %q: %v", ErrWebhookURLUnexpected, e.Rule, e.Err)
+}
+
+// Unwrap returns the reason given by the rule.
+func (e *WebhookValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Is indicates whether the given error is ErrWebhookURLUnexpected.
+func (e *WebhookValidationError) Is(target error) bool {
+	return target == ErrWebhookURLUnexpected
+}
+
+// WebhookRule is a single check applied by a WebhookValidator. Rules are
+// created using the functions provided by this package (e.g., RequireHTTPS
+// or CustomRule).
+type WebhookRule struct {
+	name  string
+	check func(u *WebhookURL) error
+
+	// err is a problem found when creating the rule (e.g., an invalid
+	// pattern), reported by NewWebhookValidator.
+	err error
+}
+
+// Name returns the name of the rule.
+func (r WebhookRule) Name() string {
+	return r.name
+}
+
+// RequireHTTPS returns a rule requiring the https scheme.
+func RequireHTTPS() WebhookRule {
+	return WebhookRule{
+		name: WebhookRuleHTTPS,
+		check: func(u *WebhookURL) error {
+			if u.Scheme != "https" {
+				return fmt.Errorf("scheme %q is not https", u.Scheme)
+			}
+
+			return nil
+		},
+	}
+}
+
+// AllowHosts returns a rule requiring the host to be one of the given hosts.
+// A host with a "*." prefix allows any subdomain of the remaining domain
+// (e.g., "*.webhook.office.com"). Hosts are compared case-insensitively and
+// ports are ignored.
+func AllowHosts(hosts ...string) WebhookRule {
+	allowed := make([]string, len(hosts))
+	for i, host := range hosts {
+		allowed[i] = strings.ToLower(host)
+	}
+
+	return WebhookRule{
+		name: WebhookRuleHosts,
+		check: func(u *WebhookURL) error {
+			for _, host := range allowed {
+				switch {
+				case strings.HasPrefix(host, "*."):
+					if strings.HasSuffix(u.Host, host[1:]) {
+						return nil
+					}
+				case u.Host == host:
+					return nil
+				}
+			}
+
+			return fmt.Errorf("host %q is not allowed", u.Host)
+		},
+	}
+}
+
+// RequireKnownPathShape returns a rule requiring the webhook URL to be a
+// Microsoft Teams incoming webhook URL including the group, tenant,
+// connector and owner IDs or a Power Automate Workflows URL including the
+// workflow ID.
+func RequireKnownPathShape() WebhookRule {
+	return WebhookRule{
+		name: WebhookRulePathShape,
+		check: func(u *WebhookURL) error {
+			switch u.HostType {
+			case WebhookHostLegacy, WebhookHostOrganization:
+				if u.GroupID == "" || u.TenantID == "" || u.ConnectorID == "" || u.OwnerID == "" {
+					return errors.New("path is not a recognized incoming webhook path")
+				}
+			case WebhookHostWorkflows:
+				if u.WorkflowID == "" {
+					return errors.New("path is not a recognized Workflows path")
+				}
+			default:
+				return fmt.Errorf("host %q is not a recognized webhook host", u.Host)
+			}
+
+			return nil
+		},
+	}
+}
+
+// MatchPattern returns a rule requiring the full webhook URL to match at
+// least one of the given regular expressions. Patterns are compiled once
+// when the rule is created; an invalid pattern is reported by
+// NewWebhookValidator.
+func MatchPattern(patterns ...string) WebhookRule {
+	rule := WebhookRule{name: WebhookRulePattern}
+
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			rule.err = fmt.Errorf("invalid pattern %q: %w", pattern, err)
+
+			return rule
+		}
+		compiled = append(compiled, re)
+	}
+
+	rule.check = func(u *WebhookURL) error {
+		for _, re := range compiled {
+			if re.MatchString(u.Raw()) {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("URL does not match patterns: %s", strings.Join(patterns, ","))
+	}
+
+	return rule
+}
+
+// CustomRule returns a rule with the given name which applies the given
+// function. The function returns an error describing why the webhook URL is
+// not valid; the error should not include the webhook URL.
+func CustomRule(name string, fn func(u *WebhookURL) error) WebhookRule {
+	rule := WebhookRule{
+		name:  name,
+		check: fn,
+	}
+
+	if fn == nil {
+		rule.err = fmt.Errorf("rule %q has no function", name)
+	}
+
+	return rule
+}
+
+// AnyOf returns a rule which passes if at least one of the given rules
+// passes (e.g., to allow either of two host and path combinations).
+func AnyOf(rules ...WebhookRule) WebhookRule {
+	rule := WebhookRule{name: WebhookRuleAnyOf}
+	rules = append([]WebhookRule(nil), rules...)
+
+	names := make([]string, len(rules))
+	for i, r := range rules {
+		switch {
+		case r.err != nil:
+			rule.err = r.err
+		case r.check == nil:
+			rule.err = errors.New("rule has no check")
+		}
+
+		if rule.err != nil {
+			return rule
+		}
+		names[i] = r.name
+	}
+
+	rule.check = func(u *WebhookURL) error {
+		for _, r := range rules {
+			if r.check(u) == nil {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("none of rules passed: %s", strings.Join(names, ","))
+	}
+
+	return rule
+}
+
+// WebhookValidator validates webhook URLs using a set of rules, each of
+// which must pass. A WebhookValidator is immutable and safe for concurrent
+// use, so a single WebhookValidator may be shared by multiple clients.
+type WebhookValidator struct {
+	rules []WebhookRule
+}
+
+// NewWebhookValidator creates a WebhookValidator which applies the given
+// rules in order. An error is returned if a rule is invalid (e.g., a rule
+// created by MatchPattern with an invalid pattern).
+func NewWebhookValidator(rules ...WebhookRule) (*WebhookValidator, error) {
+	for _, rule := range rules {
+		if rule.err != nil {
+			return nil, fmt.Errorf("failed to create webhook validator: %w", rule.err)
+		}
+
+		if rule.check == nil {
+			return nil, errors.New("failed to create webhook validator: rule has no check")
+		}
+	}
+
+	return &WebhookValidator{
+		rules: append([]WebhookRule(nil), rules...),
+	}, nil
+}
+
+// Validate applies each rule to the given webhook URL, returning a
+// *WebhookValidationError for the first rule which fails.
+func (v *WebhookValidator) Validate(webhookURL string) error {
+	u, err := ParseWebhookURL(webhookURL)
+	if err != nil {
+		return &WebhookValidationError{Rule: WebhookRuleParse, Err: err}
+	}
+
+	for _, rule := range v.rules {
+		if err := rule.check(u); err != nil {
+			return &WebhookValidationError{Rule: rule.name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// SetWebhookValidator accepts a WebhookValidator which replaces the webhook
+// URL validation patterns for the client.
+//
+// Deprecated: use the WithWebhookValidator option with NewTeamsClient or
+// TeamsClient.With instead.
+func (c *TeamsClient) SetWebhookValidator(validator *WebhookValidator) *TeamsClient {
+	c.webhookValidator = validator
+
+	return c
+}
+
+// WebhookValidator returns the configured WebhookValidator for the client or
+// nil if one has not been set.
+func (c *TeamsClient) WebhookValidator() *WebhookValidator {
+	return c.webhookValidator
+}
+
+// webhookPattern is a webhook URL validation pattern which is compiled when
+// added to a client so that it is not compiled for each message submission.
+// An error compiling the pattern is returned when a webhook URL is validated.
+type webhookPattern struct {
+	pattern string
+	re      *regexp.Regexp
+	err     error
+}
+
+// defaultWebhookPatterns are used to validate webhook URLs if no patterns
+// have been added to a client.
+var defaultWebhookPatterns = compileWebhookPatterns([]string{
+	DefaultWebhookURLValidationPattern,
+	DefaultWorkflowsWebhookURLValidationPattern,
+})
+
+// compileWebhookPatterns compiles the given webhook URL validation patterns.
+func compileWebhookPatterns(patterns []string) []webhookPattern {
+	compiled := make([]webhookPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		compiled = append(compiled, webhookPattern{pattern: pattern, re: re, err: err})
+	}
+
+	return compiled
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testIncomingWebhookURL = "https://example.webhook.office.com/webhookb2/a1269812-6d10-44b1-abc5-b84f93580ba0@9e7b80c7-d1eb-4b52-8582-76f921e416d9/IncomingWebhook/3fdd6767bae44ac58e5995547d66a4e4/f332c8d9-3397-4ac5-957b-b8e3fc465a8c"
+
+func TestWebhookValidator(t *testing.T) {
+	errStaging := errors.New("staging webhooks are not allowed")
+
+	validator, err := NewWebhookValidator(
+		RequireHTTPS(),
+		AllowHosts("*.webhook.office.com", "outlook.office.com", "*.logic.azure.com"),
+		RequireKnownPathShape(),
+		CustomRule("no-staging", func(u *WebhookURL) error {
+			if strings.HasPrefix(u.Host, "staging.") {
+				return errStaging
+			}
+
+			return nil
+		}),
+	)
+	requireNoError(t, err)
+
+	assert.NoError(t, validator.Validate(testIncomingWebhookURL))
+	assert.NoError(t, validator.Validate(testLogicAppsWebhookURL))
+
+	tests := map[string]struct {
+		webhookURL string
+		rule       string
+	}{
+		"parse": {
+			webhookURL: "not a URL",
+			rule:       WebhookRuleParse,
+		},
+		"http": {
+			webhookURL: strings.Replace(testIncomingWebhookURL, "https", "http", 1),
+			rule:       WebhookRuleHTTPS,
+		},
+		"host": {
+			webhookURL: "https://example.com/webhook",
+			rule:       WebhookRuleHosts,
+		},
+		"path": {
+			webhookURL: "https://example.webhook.office.com/webhookb2/xxx",
+			rule:       WebhookRulePathShape,
+		},
+		"custom": {
+			webhookURL: strings.Replace(testIncomingWebhookURL, "example.", "staging.", 1),
+			rule:       "no-staging",
+		},
+	}
+
+	for name, tt := range tests {
+		err := validator.Validate(tt.webhookURL)
+
+		var validationErr *WebhookValidationError
+		if !assert.True(t, errors.As(err, &validationErr), name) {
+			continue
+		}
+		assert.Equal(t, tt.rule, validationErr.Rule, name)
+		assert.True(t, errors.Is(err, ErrWebhookURLUnexpected), name)
+		assert.NotContains(t, err.Error(), "3fdd6767bae44ac58e5995547d66a4e4", name)
+	}
+
+	err = validator.Validate(strings.Replace(testIncomingWebhookURL, "example.", "staging.", 1))
+	assert.True(t, errors.Is(err, errStaging))
+}
+
+func TestWebhookValidatorPatterns(t *testing.T) {
+	validator, err := NewWebhookValidator(
+		AnyOf(
+			MatchPattern(DefaultWebhookURLValidationPattern),
+			AllowHosts("example.com"),
+		),
+	)
+	requireNoError(t, err)
+
+	assert.NoError(t, validator.Validate(testIncomingWebhookURL))
+	assert.NoError(t, validator.Validate("http://example.com/webhook"))
+
+	var validationErr *WebhookValidationError
+	if assert.True(t, errors.As(validator.Validate("https://example.org/webhook"), &validationErr)) {
+		assert.Equal(t, WebhookRuleAnyOf, validationErr.Rule)
+	}
+
+	_, err = NewWebhookValidator(MatchPattern("("))
+	assert.Error(t, err)
+
+	_, err = NewWebhookValidator(AnyOf(CustomRule("nil", nil)))
+	assert.Error(t, err)
+
+	_, err = NewWebhookValidator(WebhookRule{})
+	assert.Error(t, err)
+}
+
+func TestTeamsClientWebhookURLValidationPatterns(t *testing.T) {
+	client := NewTeamsClient(WithWebhookURLValidationPatterns(`^https://example\.com/`))
+
+	// Patterns are compiled once when added to the client.
+	if assert.Len(t, client.webhookURLValidationPatterns, 1) {
+		assert.NotNil(t, client.webhookURLValidationPatterns[0].re)
+	}
+
+	derived := client.With(WithWebhookURLValidationPatterns(`^https://example\.org/`))
+	assert.NoError(t, derived.ValidateWebhook("https://example.org/webhook"))
+	assert.Error(t, client.ValidateWebhook("https://example.org/webhook"))
+	assert.Same(t, client.webhookURLValidationPatterns[0].re, derived.webhookURLValidationPatterns[0].re)
+
+	err := client.ValidateWebhook("https://example.net/webhook")
+	assert.True(t, errors.Is(err, ErrWebhookURLUnexpected))
+	assert.Contains(t, err.Error(), `^https://example\.com/`)
+
+	// Invalid patterns are reported when a webhook URL is validated.
+	invalid := NewTeamsClient(WithWebhookURLValidationPatterns("("))
+	err = invalid.ValidateWebhook("https://example.com/webhook")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrWebhookURLUnexpected))
+}
+
+func TestTeamsClientWebhookValidator(t *testing.T) {
+	validator, err := NewWebhookValidator(RequireHTTPS(), AllowHosts("example.com"))
+	requireNoError(t, err)
+
+	var requests int
+	httpClient := newScriptedTestClient(&requests,
+		scriptedResponse{status: http.StatusOK, body: ExpectedWebhookURLResponseText},
+	)
+
+	// The same validator may be used by multiple clients.
+	client := NewTeamsClient(WithHTTPClient(httpClient), WithWebhookValidator(validator))
+	other := NewTeamsClient().SetHTTPClient(httpClient).SetWebhookValidator(validator)
+	assert.Equal(t, validator, other.WebhookValidator())
+
+	msgCard := newTestMessageCard(t)
+
+	for _, c := range []*TeamsClient{client, other} {
+		assert.NoError(t, c.Send("https://example.com/webhook", msgCard))
+
+		err := c.Send(testIncomingWebhookURL, msgCard)
+		assert.True(t, errors.Is(err, ErrWebhookURLUnexpected))
+		assert.False(t, IsRetryableError(err))
+	}
+	assert.Equal(t, 2, requests)
+
+	// Validation may still be skipped.
+	assert.NoError(t, client.With(WithSkipWebhookURLValidation(true)).ValidateWebhook(testIncomingWebhookURL))
+}
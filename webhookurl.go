@@ -0,0 +1,247 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// WebhookHostType is the kind of service providing a webhook URL.
+type WebhookHostType int
+
+const (
+	// WebhookHostUnknown indicates a host which is not recognized.
+	WebhookHostUnknown WebhookHostType = iota
+
+	// WebhookHostLegacy indicates the legacy outlook.office.com (or
+	// outlook.office365.com) host.
+	WebhookHostLegacy
+
+	// WebhookHostOrganization indicates an organization specific
+	// *.webhook.office.com host.
+	WebhookHostOrganization
+
+	// WebhookHostWorkflows indicates a Power Automate Workflows (Logic Apps)
+	// host.
+	WebhookHostWorkflows
+)
+
+// String returns the name of the host type.
+func (t WebhookHostType) String() string {
+	switch t {
+	case WebhookHostUnknown:
+		return "unknown"
+	case WebhookHostLegacy:
+		return "legacy"
+	case WebhookHostOrganization:
+		return "organization"
+	case WebhookHostWorkflows:
+		return "workflows"
+	default:
+		return fmt.Sprintf("WebhookHostType(%d)", int(t))
+	}
+}
+
+// Path segments and hosts of recognized webhook URLs.
+const (
+	webhookPathSegment         string = "webhook"
+	webhookB2PathSegment       string = "webhookb2"
+	incomingWebhookPathSegment string = "IncomingWebhook"
+	workflowsPathSegment       string = "workflows"
+	legacyWebhookHost          string = "outlook.office.com"
+	legacyOffice365WebhookHost string = "outlook.office365.com"
+	organizationWebhookSuffix  string = ".webhook.office.com"
+	logicAppsWebhookSuffix     string = ".logic.azure.com"
+	powerPlatformWebhookSuffix string = ".environment.api.powerplatform.com"
+)
+
+// WebhookURL is a parsed webhook URL. The path and query of a webhook URL
+// contain secrets; use Redacted (or String) when including a webhook URL in
+// errors or log entries.
+//
+// Microsoft Teams incoming webhook URLs take the form:
+//
+//	https://example.webhook.office.com/webhookb2/{group}@{tenant}/IncomingWebhook/{connector}/{owner}/{token}
+type WebhookURL struct {
+	// HostType is the kind of service providing the webhook URL.
+	HostType WebhookHostType
+
+	// Scheme is the URL scheme (e.g., https).
+	Scheme string
+
+	// Host is the host name, without any port.
+	Host string
+
+	// GroupID is the ID of the Microsoft 365 group (team).
+	GroupID string
+
+	// TenantID is the ID of the Azure AD tenant.
+	TenantID string
+
+	// ConnectorID is the ID of the incoming webhook connector.
+	ConnectorID string
+
+	// OwnerID is the ID of the user which created the connector.
+	OwnerID string
+
+	// Token is the validation token included in newer incoming webhook URLs.
+	Token string
+
+	// WorkflowID is the ID of a Power Automate workflow.
+	WorkflowID string
+
+	// WebhookB2 indicates whether the URL uses the webhookb2 path.
+	WebhookB2 bool
+
+	raw string
+}
+
+// ParseWebhookURL parses the given webhook URL, extracting the components
+// of Microsoft Teams incoming webhook and Power Automate Workflows URLs.
+// Other URLs are accepted with the WebhookHostUnknown host type. Errors do
+// not include the webhook URL.
+func ParseWebhookURL(webhookURL string) (*WebhookURL, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		// The url.Error includes the full URL.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return nil, fmt.Errorf("unable to parse webhook URL: %w", err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("unable to parse webhook URL: missing scheme or host")
+	}
+
+	w := WebhookURL{
+		Scheme: u.Scheme,
+		Host:   strings.ToLower(u.Hostname()),
+		raw:    webhookURL,
+	}
+
+	switch {
+	case w.Host == legacyWebhookHost, w.Host == legacyOffice365WebhookHost:
+		w.HostType = WebhookHostLegacy
+	case strings.HasSuffix(w.Host, organizationWebhookSuffix):
+		w.HostType = WebhookHostOrganization
+	case strings.HasSuffix(w.Host, logicAppsWebhookSuffix),
+		strings.HasSuffix(w.Host, powerPlatformWebhookSuffix):
+		w.HostType = WebhookHostWorkflows
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch w.HostType {
+	case WebhookHostLegacy, WebhookHostOrganization:
+		if len(segments) < 2 {
+			break
+		}
+
+		switch segments[0] {
+		case webhookB2PathSegment:
+			w.WebhookB2 = true
+		case webhookPathSegment:
+		default:
+			return &w, nil
+		}
+
+		if at := strings.Index(segments[1], "@"); at >= 0 {
+			w.GroupID = segments[1][:at]
+			w.TenantID = segments[1][at+1:]
+		}
+
+		if len(segments) > 3 && segments[2] == incomingWebhookPathSegment {
+			w.ConnectorID = segments[3]
+			if len(segments) > 4 {
+				w.OwnerID = segments[4]
+			}
+			if len(segments) > 5 {
+				w.Token = segments[5]
+			}
+		}
+
+	case WebhookHostWorkflows:
+		for i := 0; i+1 < len(segments); i++ {
+			if segments[i] == workflowsPathSegment {
+				w.WorkflowID = segments[i+1]
+				break
+			}
+		}
+	}
+
+	return &w, nil
+}
+
+// Redacted returns the scheme and host of the webhook URL, omitting the path
+// and query which contain secrets.
+func (w *WebhookURL) Redacted() string {
+	return w.Scheme + "://" + w.Host + redactedPath
+}
+
+// String returns the redacted form of the webhook URL; see Redacted.
+func (w *WebhookURL) String() string {
+	return w.Redacted()
+}
+
+// Raw returns the webhook URL as given to ParseWebhookURL, including
+// secrets.
+func (w *WebhookURL) Raw() string {
+	return w.raw
+}
+
+// redactWebhookURL returns the redacted form of the given webhook URL. Only
+// redactedPath is returned if the URL cannot be parsed.
+func redactWebhookURL(webhookURL string) string {
+	w, err := ParseWebhookURL(webhookURL)
+	if err != nil {
+		return redactedPath
+	}
+
+	return w.Redacted()
+}
+
+// webhookHost returns the host portion of the given webhook URL, omitting
+// the path and query which contain secrets. An empty string is returned if
+// the URL cannot be parsed.
+func webhookHost(webhookURL string) string {
+	w, err := ParseWebhookURL(webhookURL)
+	if err != nil {
+		return ""
+	}
+
+	return w.Host
+}
+
+// webhookHostType returns the host type of the given webhook URL.
+// WebhookHostUnknown is returned if the URL cannot be parsed.
+func webhookHostType(webhookURL string) WebhookHostType {
+	w, err := ParseWebhookURL(webhookURL)
+	if err != nil {
+		return WebhookHostUnknown
+	}
+
+	return w.HostType
+}
+
+// redactURLError replaces the URL included in a *url.Error (as returned by
+// http.Client.Do or http.NewRequest) with the redacted form of the webhook
+// URL. Other errors are returned unchanged.
+func redactURLError(err error, webhookURL string) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactWebhookURL(webhookURL)
+	}
+
+	return err
+}
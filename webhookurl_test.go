@@ -0,0 +1,108 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWebhookURL(t *testing.T) {
+	tests := map[string]WebhookURL{
+		"https://outlook.office.com/webhook/group-id@tenant-id/IncomingWebhook/connector-id/owner-id": {
+			HostType:    WebhookHostLegacy,
+			Scheme:      "https",
+			Host:        "outlook.office.com",
+			GroupID:     "group-id",
+			TenantID:    "tenant-id",
+			ConnectorID: "connector-id",
+			OwnerID:     "owner-id",
+		},
+		"https://Example.webhook.office.com/webhookb2/group-id@tenant-id/IncomingWebhook/connector-id/owner-id/token": {
+			HostType:    WebhookHostOrganization,
+			Scheme:      "https",
+			Host:        "example.webhook.office.com",
+			GroupID:     "group-id",
+			TenantID:    "tenant-id",
+			ConnectorID: "connector-id",
+			OwnerID:     "owner-id",
+			Token:       "token",
+			WebhookB2:   true,
+		},
+		"https://prod-01.westus.logic.azure.com:443/workflows/workflow-id/triggers/manual/paths/invoke?sig=secret": {
+			HostType:   WebhookHostWorkflows,
+			Scheme:     "https",
+			Host:       "prod-01.westus.logic.azure.com",
+			WorkflowID: "workflow-id",
+		},
+		"https://example.environment.api.powerplatform.com/powerautomate/automations/direct/workflows/workflow-id/triggers/manual/paths/invoke": {
+			HostType:   WebhookHostWorkflows,
+			Scheme:     "https",
+			Host:       "example.environment.api.powerplatform.com",
+			WorkflowID: "workflow-id",
+		},
+		"http://127.0.0.1:8080/webhook": {
+			HostType: WebhookHostUnknown,
+			Scheme:   "http",
+			Host:     "127.0.0.1",
+		},
+	}
+
+	for webhookURL, want := range tests {
+		got, err := ParseWebhookURL(webhookURL)
+		if !assert.NoError(t, err, webhookURL) {
+			continue
+		}
+
+		want.raw = webhookURL
+		assert.Equal(t, want, *got, webhookURL)
+		assert.Equal(t, want.Scheme+"://"+want.Host+"/REDACTED", got.Redacted())
+		assert.Equal(t, got.Redacted(), got.String())
+		assert.Equal(t, webhookURL, got.Raw())
+	}
+
+	for _, webhookURL := range []string{"", "outlook.office.com/webhook/secret", "https://outlook.office.com/%zz/secret"} {
+		_, err := ParseWebhookURL(webhookURL)
+		if assert.Error(t, err, webhookURL) && webhookURL != "" {
+			assert.NotContains(t, err.Error(), "secret")
+		}
+	}
+}
+
+func TestWebhookURLRedactedInErrors(t *testing.T) {
+	webhookURL := "https://example.webhook.office.com/webhookb2/secret-group@secret-tenant/IncomingWebhook/secret-id/secret-owner"
+
+	client := NewTeamsClient().AddWebhookURLValidationPatterns(`^https://example\.com/`)
+	err := client.ValidateWebhook(webhookURL)
+	assert.True(t, errors.Is(err, ErrWebhookURLUnexpected))
+	assert.Contains(t, err.Error(), "https://example.webhook.office.com/REDACTED")
+	assert.NotContains(t, err.Error(), "secret")
+
+	err = client.ValidateWebhook("https://example.com/%zz/secret")
+	assert.True(t, errors.Is(err, ErrWebhookURLUnexpected))
+	assert.NotContains(t, err.Error(), "secret")
+
+	client = NewTeamsClient().
+		SetHTTPClient(&http.Client{Transport: RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})})
+
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	err = client.SendWithContext(context.Background(), webhookURL, &msgCard)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "connection refused")
+		assert.Contains(t, err.Error(), "https://example.webhook.office.com/REDACTED")
+		assert.NotContains(t, err.Error(), "secret")
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rmasci/go-teams-notify/v2/botapi"
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+)
+
+// ErrUnsupportedWorkflowsPayload indicates that a message cannot be
+// converted to the Adaptive Card format expected by Power Automate
+// Workflows webhook URLs.
+var ErrUnsupportedWorkflowsPayload = errors.New("message format not supported by Workflows webhook URL")
+
+// Adaptive Card values used when submitting messages to Power Automate
+// Workflows webhook URLs.
+const (
+	// AdaptiveCardContentType is the attachment content type of an Adaptive
+	// Card.
+	AdaptiveCardContentType string = "application/vnd.microsoft.card.adaptive"
+
+	// AdaptiveCardSchema is the JSON schema of an Adaptive Card.
+	AdaptiveCardSchema string = "http://adaptivecards.io/schemas/adaptive-card.json"
+
+	// AdaptiveCardVersion is the Adaptive Card version generated for
+	// MessageCard and botapi messages.
+	AdaptiveCardVersion string = "1.4"
+)
+
+// Payload type values recognized when wrapping messages.
+const (
+	adaptiveCardType string = "AdaptiveCard"
+	messageCardType  string = "MessageCard"
+	botMessageType   string = "message"
+	openURIType      string = "OpenUri"
+)
+
+// workflowsEnvelope is the message format expected by Power Automate
+// Workflows webhook URLs.
+type workflowsEnvelope struct {
+	Type        string                `json:"type"`
+	Attachments []workflowsAttachment `json:"attachments"`
+}
+
+// workflowsAttachment is an Adaptive Card attachment.
+type workflowsAttachment struct {
+	ContentType string          `json:"contentType"`
+	ContentURL  *string         `json:"contentUrl"`
+	Content     json.RawMessage `json:"content"`
+}
+
+// adaptiveCard is the subset of the Adaptive Card format generated for
+// MessageCard and botapi messages.
+type adaptiveCard struct {
+	Type    string                   `json:"type"`
+	Schema  string                   `json:"$schema"`
+	Version string                   `json:"version"`
+	Body    []map[string]interface{} `json:"body"`
+	Actions []map[string]interface{} `json:"actions,omitempty"`
+	MSTeams adaptiveCardMSTeams      `json:"msteams"`
+}
+
+// adaptiveCardMSTeams provides Microsoft Teams specific Adaptive Card
+// settings.
+type adaptiveCardMSTeams struct {
+	Width    string           `json:"width,omitempty"`
+	Entities []botapi.Mention `json:"entities,omitempty"`
+}
+
+// workflowsPayload returns the given prepared payload in the Adaptive Card
+// attachments envelope expected by Power Automate Workflows webhook URLs.
+// Payloads already using the envelope are returned unchanged, Adaptive Cards
+// are wrapped and MessageCard or botapi messages are converted to an
+// Adaptive Card.
+func workflowsPayload(payload []byte) ([]byte, error) {
+	var envelope struct {
+		Type        string          `json:"type"`
+		SchemaType  string          `json:"@type"`
+		Attachments json.RawMessage `json:"attachments"`
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode prepared message: %w", err)
+	}
+
+	var content []byte
+	var err error
+
+	switch {
+	case len(envelope.Attachments) > 0:
+		return payload, nil
+
+	case envelope.Type == adaptiveCardType:
+		content = payload
+
+	case strings.EqualFold(envelope.SchemaType, messageCardType):
+		content, err = messageCardToAdaptiveCard(payload)
+
+	case envelope.Type == botMessageType:
+		content, err = botMessageToAdaptiveCard(payload)
+
+	default:
+		return nil, ErrUnsupportedWorkflowsPayload
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(workflowsEnvelope{
+		Type: botMessageType,
+		Attachments: []workflowsAttachment{
+			{
+				ContentType: AdaptiveCardContentType,
+				Content:     content,
+			},
+		},
+	})
+}
+
+// newAdaptiveCard creates an empty full width Adaptive Card.
+func newAdaptiveCard() adaptiveCard {
+	return adaptiveCard{
+		Type:    adaptiveCardType,
+		Schema:  AdaptiveCardSchema,
+		Version: AdaptiveCardVersion,
+		Body:    []map[string]interface{}{},
+		MSTeams: adaptiveCardMSTeams{Width: "Full"},
+	}
+}
+
+// textBlock returns an Adaptive Card TextBlock element with the given
+// settings (e.g., "weight", "Bolder").
+func textBlock(text string, settings ...string) map[string]interface{} {
+	element := map[string]interface{}{
+		"type": "TextBlock",
+		"text": text,
+		"wrap": true,
+	}
+
+	for i := 0; i+1 < len(settings); i += 2 {
+		element[settings[i]] = settings[i+1]
+	}
+
+	return element
+}
+
+// messageCardToAdaptiveCard converts the given prepared MessageCard to an
+// Adaptive Card. Only OpenUri potential actions are retained.
+func messageCardToAdaptiveCard(payload []byte) ([]byte, error) {
+	var mc messagecard.MessageCard
+	if err := json.Unmarshal(payload, &mc); err != nil {
+		return nil, fmt.Errorf("failed to decode prepared message card: %w", err)
+	}
+
+	card := newAdaptiveCard()
+
+	if mc.Title != "" {
+		card.Body = append(card.Body, textBlock(mc.Title, "size", "Large", "weight", "Bolder"))
+	}
+
+	if mc.Text != "" {
+		card.Body = append(card.Body, textBlock(mc.Text))
+	}
+
+	for _, section := range mc.Sections {
+		if section == nil {
+			continue
+		}
+
+		start := len(card.Body)
+
+		if section.ActivityTitle != "" {
+			card.Body = append(card.Body, textBlock(section.ActivityTitle, "weight", "Bolder"))
+		}
+
+		if section.ActivitySubtitle != "" {
+			subtitle := textBlock(section.ActivitySubtitle)
+			subtitle["isSubtle"] = true
+			card.Body = append(card.Body, subtitle)
+		}
+
+		if section.ActivityText != "" {
+			card.Body = append(card.Body, textBlock(section.ActivityText))
+		}
+
+		if section.Title != "" {
+			card.Body = append(card.Body, textBlock(section.Title, "size", "Medium", "weight", "Bolder"))
+		}
+
+		if section.Text != "" {
+			card.Body = append(card.Body, textBlock(section.Text))
+		}
+
+		if len(section.Facts) > 0 {
+			facts := make([]map[string]string, 0, len(section.Facts))
+			for _, fact := range section.Facts {
+				facts = append(facts, map[string]string{
+					"title": fact.Name,
+					"value": fact.Value,
+				})
+			}
+
+			card.Body = append(card.Body, map[string]interface{}{
+				"type":  "FactSet",
+				"facts": facts,
+			})
+		}
+
+		images := section.Images
+		if section.HeroImage != nil {
+			images = append([]*messagecard.SectionImage{section.HeroImage}, images...)
+		}
+
+		for _, image := range images {
+			if image == nil || image.Image == "" {
+				continue
+			}
+
+			card.Body = append(card.Body, map[string]interface{}{
+				"type":    "Image",
+				"url":     image.Image,
+				"altText": image.Title,
+			})
+		}
+
+		card.Actions = append(card.Actions, openURLActions(section.PotentialActions)...)
+
+		// Separate sections from preceding content.
+		if start > 0 && len(card.Body) > start {
+			card.Body[start]["separator"] = true
+		}
+	}
+
+	card.Actions = append(card.Actions, openURLActions(mc.PotentialActions)...)
+
+	if len(card.Body) == 0 && mc.Summary != "" {
+		card.Body = append(card.Body, textBlock(mc.Summary))
+	}
+
+	return json.Marshal(card)
+}
+
+// openURLActions converts OpenUri potential actions to Adaptive Card
+// Action.OpenUrl actions, using the default target of each.
+func openURLActions(actions []*messagecard.PotentialAction) []map[string]interface{} {
+	var converted []map[string]interface{}
+
+	for _, action := range actions {
+		if action == nil || action.Type != openURIType || len(action.Targets) == 0 {
+			continue
+		}
+
+		uri := action.Targets[0].URI
+		for _, target := range action.Targets {
+			if target.OS == "default" {
+				uri = target.URI
+				break
+			}
+		}
+
+		converted = append(converted, map[string]interface{}{
+			"type":  "Action.OpenUrl",
+			"title": action.Name,
+			"url":   uri,
+		})
+	}
+
+	return converted
+}
+
+// botMessageToAdaptiveCard converts the given prepared botapi message to an
+// Adaptive Card, retaining user mentions.
+func botMessageToAdaptiveCard(payload []byte) ([]byte, error) {
+	var msg botapi.Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return nil, fmt.Errorf("failed to decode prepared message: %w", err)
+	}
+
+	card := newAdaptiveCard()
+	card.Body = append(card.Body, textBlock(msg.Text))
+	card.MSTeams.Entities = msg.Entities
+
+	return json.Marshal(card)
+}
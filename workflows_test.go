@@ -0,0 +1,214 @@
+// Copyright 2022 Adam Chalkley
+//
+// https://github.com/atc0005/go-teams-notify
+//
+// Licensed under the MIT License. See LICENSE file in the project root for
+// full license information.
+
+package goteamsnotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rmasci/go-teams-notify/v2/botapi"
+	"github.com/rmasci/go-teams-notify/v2/messagecard"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testLogicAppsWebhookURL     = "https://prod-01.westus.logic.azure.com:443/workflows/workflow-id/triggers/manual/paths/invoke?api-version=2016-06-01&sig=secret"
+	testPowerPlatformWebhookURL = "https://example.environment.api.powerplatform.com:443/powerautomate/automations/direct/workflows/workflow-id/triggers/manual/paths/invoke?api-version=1&sig=secret"
+)
+
+// newWorkflowsTestClient returns an http.Client which records request
+// bodies and responds with the given status code and empty body.
+func newWorkflowsTestClient(bodies *[]string, statusCode int) *http.Client {
+	return NewTestClient(func(req *http.Request) (*http.Response, error) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		*bodies = append(*bodies, string(body))
+
+		return &http.Response{
+			StatusCode: statusCode,
+			Status:     http.StatusText(statusCode),
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Header:     make(http.Header),
+		}, nil
+	})
+}
+
+// decodedAttachment is the Adaptive Card attachment of a submitted payload.
+type decodedAttachment struct {
+	ContentType string `json:"contentType"`
+	Content     struct {
+		Type    string                   `json:"type"`
+		Body    []map[string]interface{} `json:"body"`
+		Actions []map[string]interface{} `json:"actions"`
+		MSTeams struct {
+			Entities []botapi.Mention `json:"entities"`
+		} `json:"msteams"`
+	} `json:"content"`
+}
+
+func decodeWorkflowsPayload(t *testing.T, payload string) decodedAttachment {
+	t.Helper()
+
+	var envelope struct {
+		Type        string              `json:"type"`
+		Attachments []decodedAttachment `json:"attachments"`
+	}
+	requireNoError(t, json.Unmarshal([]byte(payload), &envelope))
+
+	assert.Equal(t, "message", envelope.Type)
+	if !assert.Len(t, envelope.Attachments, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, AdaptiveCardContentType, envelope.Attachments[0].ContentType)
+	assert.Equal(t, "AdaptiveCard", envelope.Attachments[0].Content.Type)
+
+	return envelope.Attachments[0]
+}
+
+func TestTeamsClientWorkflowsMessageCard(t *testing.T) {
+	var bodies []string
+	client := NewTeamsClient().SetHTTPClient(newWorkflowsTestClient(&bodies, http.StatusAccepted))
+
+	msgCard := messagecard.NewMessageCard()
+	msgCard.Title = "Deployment"
+	msgCard.Text = "Deployment finished"
+
+	section := messagecard.NewSection()
+	section.ActivityTitle = "Build 42"
+	requireNoError(t, section.AddFactFromKeyValue("Status", "passed"))
+	requireNoError(t, msgCard.AddSection(section))
+
+	action, err := messagecard.NewPotentialAction(messagecard.PotentialActionOpenURIType, "View")
+	requireNoError(t, err)
+	action.PotentialActionOpenURI.Targets = []messagecard.PotentialActionOpenURITarget{
+		{OS: "default", URI: "https://example.com/builds/42"},
+	}
+	requireNoError(t, msgCard.AddPotentialAction(action))
+
+	for _, webhookURL := range []string{testLogicAppsWebhookURL, testPowerPlatformWebhookURL} {
+		assert.NoError(t, client.SendWithContext(context.Background(), webhookURL, msgCard))
+	}
+
+	if !assert.Len(t, bodies, 2) {
+		return
+	}
+
+	attachment := decodeWorkflowsPayload(t, bodies[0])
+	body := attachment.Content.Body
+	if assert.Len(t, body, 4) {
+		assert.Equal(t, "Deployment", body[0]["text"])
+		assert.Equal(t, "Deployment finished", body[1]["text"])
+		assert.Equal(t, "Build 42", body[2]["text"])
+		assert.Equal(t, true, body[2]["separator"])
+		assert.Equal(t, "FactSet", body[3]["type"])
+	}
+	if assert.Len(t, attachment.Content.Actions, 1) {
+		assert.Equal(t, "Action.OpenUrl", attachment.Content.Actions[0]["type"])
+		assert.Equal(t, "https://example.com/builds/42", attachment.Content.Actions[0]["url"])
+	}
+}
+
+func TestTeamsClientWorkflowsSplitOversizedMessages(t *testing.T) {
+	const maxSize = 2000
+
+	var bodies []string
+	client := NewTeamsClient().
+		SetHTTPClient(newWorkflowsTestClient(&bodies, http.StatusAccepted)).
+		SetMaxPayloadSize(maxSize).
+		SetSplitOversizedMessages(true)
+
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("step %03d: done", i))
+	}
+
+	msgCard := messagecard.NewMessageCard()
+	msgCard.Title = "Build log"
+	msgCard.Text = strings.Join(lines, "\n")
+
+	section := messagecard.NewSection()
+	for i := 0; i < 100; i++ {
+		requireNoError(t, section.AddFactFromKeyValue(fmt.Sprintf("Step %d", i), "1.5s"))
+	}
+	requireNoError(t, msgCard.AddSection(section))
+
+	err := client.SendWithContext(context.Background(), testLogicAppsWebhookURL, msgCard)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Greater(t, len(bodies), 1)
+	for _, body := range bodies {
+		assert.LessOrEqual(t, len(body), maxSize)
+		decodeWorkflowsPayload(t, body)
+	}
+}
+
+func TestTeamsClientWorkflowsBotMessage(t *testing.T) {
+	var bodies []string
+	client := NewTeamsClient().SetHTTPClient(newWorkflowsTestClient(&bodies, http.StatusAccepted))
+
+	msg := botapi.NewMessage().AddText("Please review")
+	requireNoError(t, msg.Mention("Some User", "some.user@example.com", true))
+
+	assert.NoError(t, client.Send(testLogicAppsWebhookURL, msg))
+
+	if assert.Len(t, bodies, 1) {
+		attachment := decodeWorkflowsPayload(t, bodies[0])
+		assert.Contains(t, attachment.Content.Body[0]["text"], "<at>Some User</at>")
+		assert.Len(t, attachment.Content.MSTeams.Entities, 1)
+	}
+}
+
+func TestTeamsClientWorkflowsRawPayloads(t *testing.T) {
+	var bodies []string
+	client := NewTeamsClient().SetHTTPClient(newWorkflowsTestClient(&bodies, http.StatusAccepted))
+
+	adaptiveCard := `{"type":"AdaptiveCard","version":"1.4","body":[{"type":"TextBlock","text":"Hello"}]}`
+	assert.NoError(t, client.Send(testLogicAppsWebhookURL, NewRawMessage([]byte(adaptiveCard))))
+
+	envelope := `{"type":"message","attachments":[{"contentType":"application/vnd.microsoft.card.adaptive","content":{"type":"AdaptiveCard"}}]}`
+	assert.NoError(t, client.Send(testLogicAppsWebhookURL, NewRawMessage([]byte(envelope))))
+
+	err := client.Send(testLogicAppsWebhookURL, NewRawMessage([]byte(`{"text":"Hello"}`)))
+	assert.True(t, errors.Is(err, ErrUnsupportedWorkflowsPayload))
+	assert.False(t, IsRetryableError(err))
+
+	if assert.Len(t, bodies, 2) {
+		decodeWorkflowsPayload(t, bodies[0])
+		assert.Equal(t, envelope, bodies[1])
+	}
+}
+
+func TestTeamsClientWorkflowsSuccessCriteria(t *testing.T) {
+	msgCard := NewMessageCard()
+	msgCard.Text = "Hello World"
+
+	var bodies []string
+	client := NewTeamsClient().SetHTTPClient(newWorkflowsTestClient(&bodies, http.StatusBadRequest))
+	err := client.Send(testLogicAppsWebhookURL, &msgCard)
+	assert.True(t, errors.Is(err, ErrBadRequest))
+	assert.NotContains(t, err.Error(), "secret")
+
+	// A 202 with an empty body is not a success for other webhook URLs.
+	client = NewTeamsClient().SetHTTPClient(newWorkflowsTestClient(&bodies, http.StatusAccepted))
+	err = client.Send("https://outlook.office.com/webhook/xxx", &msgCard)
+	assert.True(t, errors.Is(err, ErrInvalidWebhookURLResponseText))
+
+	// Workflows webhook URLs must use HTTPS.
+	err = client.ValidateWebhook(strings.Replace(testLogicAppsWebhookURL, "https", "http", 1))
+	assert.True(t, errors.Is(err, ErrWebhookURLUnexpected))
+}